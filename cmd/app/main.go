@@ -5,11 +5,18 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"url-saver-bot/internal/archive"
+	"url-saver-bot/internal/archive/fs"
 	tgClient "url-saver-bot/internal/clients/telegram"
 	"url-saver-bot/internal/config"
 	eventConsumer "url-saver-bot/internal/consumer/event-consumer"
 	"url-saver-bot/internal/events/telegram"
+	"url-saver-bot/internal/ml/parser"
+	pb "url-saver-bot/internal/proto"
 	"url-saver-bot/internal/storage/db"
+
+	// time zones set by users don't depend on the zoneinfo of the host
+	_ "time/tzdata"
 )
 
 const batchSize = 100
@@ -20,15 +27,59 @@ func main() {
 	cfg := config.NewConfig()
 	ctx := context.Background()
 
+	storage := db.NewDBStorage(ctx, cfg.DatabaseDSN)
+	fetcher, err := parser.NewFetcher(parser.FetcherConfig{
+		Timeout:         cfg.FetchTimeout,
+		MaxBodySize:     cfg.FetchMaxBodySize,
+		MaxRedirects:    cfg.FetchMaxRedirects,
+		UserAgent:       cfg.UserAgent,
+		AcceptLanguage:  cfg.AcceptLanguage,
+		HostConcurrency: cfg.HostConcurrency,
+		HostDelay:       cfg.HostDelay,
+		AllowNets:       cfg.FetchAllowNets,
+		DenyNets:        cfg.FetchDenyNets,
+		RespectRobots:   cfg.RespectRobots,
+		RobotsTTL:       cfg.RobotsTTL,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	blobStore, err := fs.NewFSStore(cfg.SnapshotDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	archiver := archive.New(blobStore, storage, cfg.SnapshotQuota)
+	go archive.NewCollector(ctx, blobStore, storage).Start()
+	conn, err := parser.DialClassifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	classifier := pb.NewBertClassifierClient(conn)
+	tagWorker := parser.NewTagWorker(ctx, storage, fetcher, archiver, classifier, cfg.TagBufferSize, cfg.ContentCacheTTL)
+	go parser.NewReclassifier(ctx, storage, tagWorker).Start()
+	go parser.NewLinkChecker(ctx, storage, fetcher, cfg.LinkCheckInterval).Start()
+
+	client, err := tgClient.NewClient(cfg.Token,
+		tgClient.WithBaseURL(cfg.APIURL),
+		tgClient.WithPollTimeout(cfg.PollTimeout),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
 	eventProcessor := telegram.New(
 		ctx,
-		tgClient.NewClient(cfg.Token),
-		db.NewDBStorage(ctx, cfg.DatabaseDSN),
-		cfg.TagBufferSize,
+		client,
+		storage,
+		tagWorker,
+		archiver,
+		cfg.ListsAsFile,
 	)
+	go telegram.NewDigester(ctx, client, storage, cfg.DigestSize).Start()
+	go telegram.NewReminders(ctx, client, storage).Start()
 	log.Println("service started")
 
-	consumer := eventConsumer.New(eventProcessor, eventProcessor, batchSize)
+	consumer := eventConsumer.New(ctx, eventProcessor, eventProcessor, batchSize)
 	if err := consumer.Start(); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"url-saver-bot/internal/storage"
+)
+
+// BlobStore keeps snapshot files. Keys are content hashes,
+// so the same snapshot saved by several users is stored once.
+// Putting a blob that is already stored marks it written again.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the keys of blobs last written before the time
+	List(ctx context.Context, before time.Time) ([]string, error)
+	// Delete removes the blob unless it was written since the time
+	Delete(ctx context.Context, key string, before time.Time) error
+}
+
+// Page is what the snapshot is made of
+type Page struct {
+	URL   string
+	Title string
+	HTML  []byte
+	Text  string
+}
+
+// Archiver makes compressed snapshots of pages and keeps per user storage quota
+type Archiver struct {
+	store   BlobStore
+	storage storage.Storage
+	quota   int64
+}
+
+func New(store BlobStore, s storage.Storage, quota int64) *Archiver {
+	return &Archiver{
+		store:   store,
+		storage: s,
+		quota:   quota,
+	}
+}
+
+// Create stores the snapshot of the page and returns its key and compressed size
+func (a *Archiver) Create(ctx context.Context, p Page, captured time.Time) (string, int64, error) {
+	doc, err := render(p, captured)
+	if err != nil {
+		return "", 0, fmt.Errorf("can't render snapshot: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(doc); err != nil {
+		return "", 0, fmt.Errorf("can't compress snapshot: %w", err)
+	}
+	if err = zw.Close(); err != nil {
+		return "", 0, fmt.Errorf("can't compress snapshot: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	key := hex.EncodeToString(sum[:])
+	if err = a.store.Put(ctx, key, buf.Bytes()); err != nil {
+		return "", 0, fmt.Errorf("can't store snapshot: %w", err)
+	}
+
+	return key, int64(buf.Len()), nil
+}
+
+// Attach gives the user the stored snapshot if it fits into the user's quota.
+// A newer snapshot of the same page replaces the old one.
+func (a *Archiver) Attach(ctx context.Context, snapshot *storage.Snapshot) error {
+	old, err := a.storage.GetSnapshot(ctx, snapshot.URL, snapshot.UserName)
+	var nr *storage.NoResultError
+	if err == nil && old.Key == snapshot.Key {
+		return nil
+	} else if err != nil && !errors.As(err, &nr) {
+		return err
+	}
+
+	saved, err := a.storage.SaveSnapshot(ctx, snapshot, a.quota)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return NewQuotaExceededError(a.quota)
+	}
+	return nil
+}
+
+// Open returns the uncompressed snapshot of the user's page
+func (a *Archiver) Open(ctx context.Context, URL string, userName string) ([]byte, error) {
+	snapshot, err := a.storage.GetSnapshot(ctx, URL, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := a.store.Get(ctx, snapshot.Key)
+	if err != nil {
+		return nil, fmt.Errorf("can't read snapshot: %w", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	doc, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress snapshot: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"url-saver-bot/internal/storage"
+)
+
+const (
+	collectTick = time.Hour
+	// collectAfter keeps fresh blobs, a snapshot is stored a moment before the user gets it
+	collectAfter     = time.Hour
+	collectBatchSize = 500
+)
+
+// Collector deletes blobs no snapshot and no cached content points to anymore:
+// snapshots of removed pages and the ones replaced by newer snapshots.
+type Collector struct {
+	store   BlobStore
+	storage storage.Storage
+	ctx     context.Context
+}
+
+func NewCollector(ctx context.Context, store BlobStore, s storage.Storage) *Collector {
+	return &Collector{
+		store:   store,
+		storage: s,
+		ctx:     ctx,
+	}
+}
+
+func (c *Collector) Start() {
+	ticker := time.NewTicker(collectTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Collect(time.Now()); err != nil {
+				log.Printf("[ERR] snapshot collector: %v", err)
+			}
+		}
+	}
+}
+
+// Collect deletes the unused blobs written long enough before the given time
+func (c *Collector) Collect(now time.Time) error {
+	before := now.Add(-collectAfter)
+	keys, err := c.store.List(c.ctx, before)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += collectBatchSize {
+		end := start + collectBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		unused, err := c.storage.UnusedSnapshotKeys(c.ctx, keys[start:end])
+		if err != nil {
+			return err
+		}
+		// a blob stored again since the listing is being attached, Delete keeps it
+		for _, key := range unused {
+			if err = c.store.Delete(c.ctx, key, before); err != nil {
+				return fmt.Errorf("can't delete blob %v: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
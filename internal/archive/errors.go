@@ -0,0 +1,15 @@
+package archive
+
+import "fmt"
+
+type QuotaExceededError struct {
+	Quota int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("snapshot storage quota of %d bytes exceeded", e.Quota)
+}
+
+func NewQuotaExceededError(quota int64) error {
+	return &QuotaExceededError{Quota: quota}
+}
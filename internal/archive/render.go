@@ -0,0 +1,210 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// contentPolicy keeps an opened snapshot offline: nothing runs and nothing is loaded from the network
+const contentPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:"
+
+// removedTags have active content or can't work without the original site
+var removedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Base:     true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Template: true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+}
+
+// linkAttrs are made absolute so links still point to the site
+var linkAttrs = map[string]bool{
+	"href": true,
+	"cite": true,
+}
+
+// sourceAttrs are kept only for images inlined into the page, a snapshot loads nothing from the site
+var sourceAttrs = map[string]bool{
+	"src":    true,
+	"poster": true,
+}
+
+// render makes a self-contained HTML document: the sanitized page with a header
+// telling where and when it was saved, followed by the extracted text
+func render(p Page, captured time.Time) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(p.HTML))
+	if err != nil {
+		return nil, err
+	}
+
+	base, _ := url.Parse(p.URL)
+	sanitize(doc, base)
+
+	head, body := find(doc, atom.Head), find(doc, atom.Body)
+	if head == nil || body == nil {
+		return nil, errors.New("document has no head or body")
+	}
+
+	head.InsertBefore(element(atom.Meta, "", "http-equiv", "Content-Security-Policy", "content", contentPolicy), head.FirstChild)
+	head.InsertBefore(element(atom.Meta, "", "charset", "utf-8"), head.FirstChild)
+	if find(head, atom.Title) == nil && p.Title != "" {
+		head.AppendChild(element(atom.Title, p.Title))
+	}
+
+	header := element(atom.Header, "", "id", "snapshot-header")
+	header.AppendChild(element(atom.P, "Saved "+captured.UTC().Format("2006-01-02 15:04 MST")+" from "))
+	header.FirstChild.AppendChild(element(atom.A, p.URL, "href", p.URL))
+	header.AppendChild(element(atom.Hr, ""))
+	body.InsertBefore(header, body.FirstChild)
+
+	if p.Text != "" {
+		section := element(atom.Section, "", "id", "snapshot-text")
+		section.AppendChild(element(atom.Hr, ""))
+		if p.Title != "" {
+			section.AppendChild(element(atom.H1, p.Title))
+		}
+		for _, paragraph := range strings.Split(p.Text, "\n\n") {
+			section.AppendChild(element(atom.P, paragraph))
+		}
+		body.AppendChild(section)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n")
+	if err = html.Render(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sanitize removes active content, event handlers and script URLs
+// and resolves relative URLs against the page URL
+func sanitize(n *html.Node, base *url.URL) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.ElementNode:
+			if removedTags[c.DataAtom] {
+				n.RemoveChild(c)
+			} else if c.DataAtom == atom.Img && !isInline(c) {
+				// an image of the site is replaced by its description
+				if alt := attr(c, "alt"); strings.TrimSpace(alt) != "" {
+					n.InsertBefore(&html.Node{Type: html.TextNode, Data: alt}, c)
+				}
+				n.RemoveChild(c)
+			} else {
+				sanitizeAttrs(c, base)
+				sanitize(c, base)
+			}
+		case html.CommentNode, html.DoctypeNode:
+			n.RemoveChild(c)
+		default:
+			sanitize(c, base)
+		}
+		c = next
+	}
+}
+
+func sanitizeAttrs(n *html.Node, base *url.URL) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") || key == "srcset" || key == "formaction" || key == "action" {
+			continue
+		}
+		if sourceAttrs[key] && !isInlineImage(a.Val) {
+			continue
+		}
+		if linkAttrs[key] {
+			u, ok := resolve(a.Val, base)
+			if !ok {
+				continue
+			}
+			a.Val = u
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+// resolve makes the URL absolute and drops anything but web links
+func resolve(raw string, base *url.URL) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
+
+func isInlineImage(raw string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(raw)), "data:image/")
+}
+
+// isInline tells images whose data is in the page
+func isInline(n *html.Node) bool {
+	return isInlineImage(attr(n, "src"))
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// element creates the node with the text inside and attributes given as key and value pairs
+func element(a atom.Atom, text string, attrs ...string) *html.Node {
+	n := &html.Node{Type: html.ElementNode, DataAtom: a, Data: a.String()}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.Attr = append(n.Attr, html.Attribute{Key: attrs[i], Val: attrs[i+1]})
+	}
+	if text != "" {
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	}
+	return n
+}
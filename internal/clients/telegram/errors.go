@@ -2,10 +2,15 @@ package telegram
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 )
 
+// RequestError is a failure to get the answer of the API. The API may have done what
+// was asked, only requests with Unsent set never reached it and are safe to repeat.
 type RequestError struct {
-	err error
+	Unsent bool
+	err    error
 }
 
 func (e *RequestError) Error() string {
@@ -17,3 +22,100 @@ func NewRequestError(e error) error {
 		err: fmt.Errorf("request error: %w", e),
 	}
 }
+
+// NewUnsentError is the RequestError of a request that failed before it was written, e.g. on dial
+func NewUnsentError(e error) error {
+	return &RequestError{
+		Unsent: true,
+		err:    fmt.Errorf("request error: %w", e),
+	}
+}
+
+// APIError is returned when the Bot API answers with ok set to false.
+// Errors callers act on are wrapped in the typed errors below.
+type APIError struct {
+	Code        int
+	Description string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram api error %d: %v", e.Code, e.Description)
+}
+
+// RateLimitError is returned on 429, the request may be repeated after RetryAfter
+type RateLimitError struct {
+	RetryAfter time.Duration
+	err        *APIError
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", e.err, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.err
+}
+
+// ForbiddenError is returned when the bot can't write to the chat: the user blocked it,
+// or the bot was kicked from the group
+type ForbiddenError struct {
+	err *APIError
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return e.err
+}
+
+// BadRequestError is returned on 400, e.g. when the chat is not found or the message is malformed
+type BadRequestError struct {
+	err *APIError
+}
+
+func (e *BadRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *BadRequestError) Unwrap() error {
+	return e.err
+}
+
+// ChatMigratedError is returned when the group became a supergroup with the new ChatID
+type ChatMigratedError struct {
+	ChatID int
+	err    *APIError
+}
+
+func (e *ChatMigratedError) Error() string {
+	return fmt.Sprintf("%v, chat moved to %v", e.err, e.ChatID)
+}
+
+func (e *ChatMigratedError) Unwrap() error {
+	return e.err
+}
+
+// NewAPIError makes the typed error of the response
+func NewAPIError(code int, description string, params *ResponseParameters) error {
+	e := &APIError{
+		Code:        code,
+		Description: description,
+	}
+	switch {
+	case params != nil && params.MigrateToChatID != 0:
+		return &ChatMigratedError{ChatID: params.MigrateToChatID, err: e}
+	case code == http.StatusTooManyRequests:
+		var retryAfter time.Duration
+		if params != nil {
+			retryAfter = time.Duration(params.RetryAfter) * time.Second
+		}
+		return &RateLimitError{RetryAfter: retryAfter, err: e}
+	case code == http.StatusForbidden:
+		return &ForbiddenError{err: e}
+	case code == http.StatusBadRequest:
+		return &BadRequestError{err: e}
+	}
+	return e
+}
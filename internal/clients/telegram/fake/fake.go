@@ -0,0 +1,369 @@
+// Package fake is an in-process Bot API server for tests. It records what the bot sends
+// and serves updates pushed by the test.
+package fake
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"url-saver-bot/internal/clients/telegram"
+)
+
+// Token is accepted by the server, any other token gets 401 like from the real API
+const Token = "123456:fake-token"
+
+const maxMessageLength = 4096
+
+// maxPoll caps long polling, so tests don't wait for the bot's poll timeout
+const maxPoll = time.Second
+
+// Sent is a request the bot made to send something. MessageID is the id of the sent
+// or edited message, CallbackID is the id of the answered button press.
+type Sent struct {
+	Method      string
+	ChatID      int
+	MessageID   int
+	CallbackID  string
+	Text        string
+	Entities    []telegram.MessageEntity
+	ParseMode   string
+	ReplyMarkup *telegram.InlineKeyboardMarkup
+	FileName    string
+	Data        []byte
+	Caption     string
+}
+
+// failure is the error response the next request of the method gets
+type failure struct {
+	code        int
+	description string
+	params      *telegram.ResponseParameters
+}
+
+type Server struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	updates  []telegram.Update
+	nextID   int
+	sent     []Sent
+	failures map[string][]failure
+	// changed is closed and replaced when updates are pushed or messages sent
+	changed chan struct{}
+}
+
+func NewServer() *Server {
+	s := &Server{
+		nextID:   1,
+		failures: make(map[string][]failure),
+		changed:  make(chan struct{}),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL is the base URL for telegram.WithBaseURL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// PushMessage queues a text message from the user
+func (s *Server) PushMessage(chatID int, userName string, text string) {
+	s.PushUpdate(telegram.Update{Message: &telegram.IncomingMessage{
+		Chat: telegram.Chat{ID: chatID},
+		From: telegram.User{ID: chatID, UserName: userName},
+		Text: text,
+	}})
+}
+
+// PushCallback queues a press of an inline button with the given data
+func (s *Server) PushCallback(chatID int, userName string, data string) {
+	s.PushCallbackOn(chatID, userName, 0, data)
+}
+
+// PushCallbackOn queues a press of an inline button under the message with the id
+func (s *Server) PushCallbackOn(chatID int, userName string, messageID int, data string) {
+	s.PushUpdate(telegram.Update{CallbackQuery: &telegram.CallbackQuery{
+		From:    telegram.User{ID: chatID, UserName: userName},
+		Message: telegram.IncomingMessage{MessageID: messageID, Chat: telegram.Chat{ID: chatID}},
+		Data:    data,
+	}})
+}
+
+// PushUpdate queues the update, its id is set by the server
+func (s *Server) PushUpdate(u telegram.Update) {
+	s.mu.Lock()
+	u.ID = s.nextID
+	s.nextID++
+	if u.CallbackQuery != nil && u.CallbackQuery.ID == "" {
+		u.CallbackQuery.ID = strconv.Itoa(u.ID)
+	}
+	s.updates = append(s.updates, u)
+	s.notify()
+	s.mu.Unlock()
+}
+
+// FailNext makes the next request of the method fail with the code and description.
+// retryAfter is sent in the parameters of 429 responses.
+func (s *Server) FailNext(method string, code int, description string, retryAfter int) {
+	f := failure{code: code, description: description}
+	if retryAfter > 0 {
+		f.params = &telegram.ResponseParameters{RetryAfter: retryAfter}
+	}
+	s.mu.Lock()
+	s.failures[method] = append(s.failures[method], f)
+	s.mu.Unlock()
+}
+
+// Sent returns everything sent so far
+func (s *Server) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// WaitSent waits until n requests are sent and returns them, or returns what was sent by the timeout
+func (s *Server) WaitSent(n int, timeout time.Duration) []Sent {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if len(s.sent) >= n {
+			sent := append([]Sent(nil), s.sent...)
+			s.mu.Unlock()
+			return sent
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return s.Sent()
+		}
+	}
+}
+
+// notify wakes up waiters, s.mu must be held
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	s.mu.Lock()
+	if fs := s.failures[method]; len(fs) > 0 {
+		s.failures[method] = fs[1:]
+		s.mu.Unlock()
+		writeError(w, fs[0].code, fs[0].description, fs[0].params)
+		return
+	}
+	s.mu.Unlock()
+
+	switch method {
+	case "getUpdates":
+		s.getUpdates(w, r)
+	case "sendMessage":
+		s.sendMessage(w, r)
+	case "sendDocument":
+		s.sendDocument(w, r)
+	case "editMessageText":
+		s.editMessage(w, r)
+	case "answerCallbackQuery":
+		s.answerCallback(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found", nil)
+	}
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request) {
+	var req telegram.UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+
+	wait := time.Duration(req.Timeout) * time.Second
+	if wait > maxPoll {
+		wait = maxPoll
+	}
+	deadline := time.After(wait)
+	for {
+		s.mu.Lock()
+		// updates before the offset are confirmed and forgotten like by the real API
+		for len(s.updates) > 0 && s.updates[0].ID < req.Offset {
+			s.updates = s.updates[1:]
+		}
+		updates := s.updates
+		if req.Limit > 0 && len(updates) > req.Limit {
+			updates = updates[:req.Limit]
+		}
+		updates = append([]telegram.Update(nil), updates...)
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeResult(w, updates)
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			writeResult(w, updates)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) sendMessage(w http.ResponseWriter, r *http.Request) {
+	var req telegram.MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "Bad Request: message text is empty", nil)
+		return
+	}
+	if len(utf16.Encode([]rune(req.Text))) > maxMessageLength {
+		writeError(w, http.StatusBadRequest, "Bad Request: message is too long", nil)
+		return
+	}
+
+	id := s.record(Sent{
+		Method:      "sendMessage",
+		ChatID:      req.ChatID,
+		Text:        req.Text,
+		Entities:    req.Entities,
+		ParseMode:   req.ParseMode,
+		ReplyMarkup: req.ReplyMarkup,
+	})
+	writeResult(w, message(id, req.ChatID))
+}
+
+func (s *Server) sendDocument(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+	chatID, err := strconv.Atoi(r.FormValue("chat_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: chat not found", nil)
+		return
+	}
+	file, header, err := r.FormFile("document")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: there is no document in the request", nil)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+
+	id := s.record(Sent{
+		Method:   "sendDocument",
+		ChatID:   chatID,
+		FileName: header.Filename,
+		Data:     data,
+		Caption:  r.FormValue("caption"),
+	})
+	writeResult(w, message(id, chatID))
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request) {
+	var req telegram.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+	if len(utf16.Encode([]rune(req.Text))) > maxMessageLength {
+		writeError(w, http.StatusBadRequest, "Bad Request: MESSAGE_TOO_LONG", nil)
+		return
+	}
+
+	s.mu.Lock()
+	found := req.MessageID > 0 && req.MessageID <= len(s.sent) && s.sent[req.MessageID-1].ChatID == req.ChatID &&
+		strings.HasPrefix(s.sent[req.MessageID-1].Method, "send")
+	s.mu.Unlock()
+	if !found {
+		writeError(w, http.StatusBadRequest, "Bad Request: message to edit not found", nil)
+		return
+	}
+
+	s.record(Sent{
+		Method:      "editMessageText",
+		ChatID:      req.ChatID,
+		MessageID:   req.MessageID,
+		Text:        req.Text,
+		ParseMode:   req.ParseMode,
+		ReplyMarkup: req.ReplyMarkup,
+	})
+	writeResult(w, message(req.MessageID, req.ChatID))
+}
+
+func (s *Server) answerCallback(w http.ResponseWriter, r *http.Request) {
+	var req telegram.AnswerCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error(), nil)
+		return
+	}
+
+	s.record(Sent{Method: "answerCallbackQuery", CallbackID: req.CallbackQueryID, Text: req.Text})
+	writeResult(w, true)
+}
+
+// record saves the sent request, new messages get their position as the id
+func (s *Server) record(sent Sent) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sent.MessageID == 0 && strings.HasPrefix(sent.Method, "send") {
+		sent.MessageID = len(s.sent) + 1
+	}
+	s.sent = append(s.sent, sent)
+	s.notify()
+	return len(s.sent)
+}
+
+func message(id int, chatID int) map[string]any {
+	return map[string]any{
+		"message_id": id,
+		"date":       time.Now().Unix(),
+		"chat":       map[string]any{"id": chatID},
+	}
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+func writeError(w http.ResponseWriter, code int, description string, params *telegram.ResponseParameters) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(telegram.MessageResponse{
+		OK:          false,
+		ErrorCode:   code,
+		Description: description,
+		Parameters:  params,
+	})
+}
@@ -0,0 +1,295 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Priority orders queued sends, interactive replies go ahead of bulk notifications
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota
+	PriorityBulk
+)
+
+// SendLimits are the pauses between messages sent overall, to a private chat and to a group
+type SendLimits struct {
+	Global  time.Duration
+	Private time.Duration
+	Group   time.Duration
+}
+
+// DefaultSendLimits follow the Bot API limits: about 30 messages a second overall,
+// one a second to a chat and 20 a minute to a group
+var DefaultSendLimits = SendLimits{
+	Global:  time.Second / 30,
+	Private: time.Second,
+	Group:   time.Minute / 20,
+}
+
+const (
+	maxAttempts  = 5
+	retryBackoff = 500 * time.Millisecond
+	// idleWait is how long the scheduler sleeps with nothing to send, new sends wake it up
+	idleWait = time.Hour
+)
+
+type sendResult struct {
+	data []byte
+	err  error
+}
+
+type sendJob struct {
+	chatID      int
+	priority    Priority
+	seq         uint64
+	method      string
+	contentType string
+	body        []byte
+	attempts    int
+	notBefore   time.Time
+	// free jobs don't wait for the chat's slot and don't take it, like answers to button presses
+	free bool
+	done chan sendResult
+}
+
+// scheduler sends requests to chats within the rate limits. Sends to one chat go one at a time
+// in the queue order, so parts of a long message arrive in order.
+type scheduler struct {
+	mu         sync.Mutex
+	queue      []*sendJob
+	seq        uint64
+	chatNext   map[int]time.Time
+	inFlight   map[int]bool
+	globalNext time.Time
+	wake       chan struct{}
+	limits     SendLimits
+	send       func(method string, contentType string, body []byte) ([]byte, error)
+}
+
+func newScheduler(limits SendLimits, send func(method string, contentType string, body []byte) ([]byte, error)) *scheduler {
+	s := &scheduler{
+		limits:   limits,
+		chatNext: make(map[int]time.Time),
+		inFlight: make(map[int]bool),
+		wake:     make(chan struct{}, 1),
+		send:     send,
+	}
+	go s.run()
+	return s
+}
+
+// do queues the request and waits for its response
+func (s *scheduler) do(chatID int, priority Priority, method string, contentType string, body []byte) ([]byte, error) {
+	return s.wait(&sendJob{
+		chatID:      chatID,
+		priority:    priority,
+		method:      method,
+		contentType: contentType,
+		body:        body,
+	})
+}
+
+// doFree sends the request ahead of the chat's messages, only the global limit applies to it
+func (s *scheduler) doFree(chatID int, method string, contentType string, body []byte) ([]byte, error) {
+	return s.wait(&sendJob{
+		chatID:      chatID,
+		priority:    PriorityInteractive,
+		method:      method,
+		contentType: contentType,
+		body:        body,
+		free:        true,
+	})
+}
+
+func (s *scheduler) wait(j *sendJob) ([]byte, error) {
+	j.done = make(chan sendResult, 1)
+
+	s.mu.Lock()
+	s.seq++
+	j.seq = s.seq
+	s.push(j)
+	s.mu.Unlock()
+	s.signal()
+
+	r := <-j.done
+	return r.data, r.err
+}
+
+// push inserts the job after jobs of the same or higher priority, s.mu must be held
+func (s *scheduler) push(j *sendJob) {
+	i := sort.Search(len(s.queue), func(i int) bool {
+		q := s.queue[i]
+		return q.priority > j.priority || q.priority == j.priority && q.seq > j.seq
+	})
+	s.queue = append(s.queue, nil)
+	copy(s.queue[i+1:], s.queue[i:])
+	s.queue[i] = j
+}
+
+func (s *scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	timer := time.NewTimer(idleWait)
+	for {
+		j, wait := s.next()
+		if j != nil {
+			go s.dispatch(j)
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// next takes the first job that may be sent now, or tells how long to wait for one
+func (s *scheduler) next() (*sendJob, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.queue) == 0 {
+		s.prune(now)
+		return nil, idleWait
+	}
+	if now.Before(s.globalNext) {
+		return nil, s.globalNext.Sub(now)
+	}
+
+	wait := idleWait
+	seen := make(map[int]bool)
+	for i, j := range s.queue {
+		if j.free {
+			if j.notBefore.After(now) {
+				if d := j.notBefore.Sub(now); d < wait {
+					wait = d
+				}
+				continue
+			}
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.globalNext = now.Add(s.limits.Global)
+			return j, 0
+		}
+
+		// only the first queued job of a chat may go
+		if seen[j.chatID] || s.inFlight[j.chatID] {
+			seen[j.chatID] = true
+			continue
+		}
+		seen[j.chatID] = true
+
+		ready := s.chatNext[j.chatID]
+		if j.notBefore.After(ready) {
+			ready = j.notBefore
+		}
+		if ready.After(now) {
+			if d := ready.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+		s.inFlight[j.chatID] = true
+		s.chatNext[j.chatID] = now.Add(s.chatInterval(j.chatID))
+		s.globalNext = now.Add(s.limits.Global)
+		return j, 0
+	}
+	return nil, wait
+}
+
+// dispatch sends the job and queues it again when the failure is temporary
+func (s *scheduler) dispatch(j *sendJob) {
+	data, err := s.send(j.method, j.contentType, j.body)
+
+	s.mu.Lock()
+	if !j.free {
+		delete(s.inFlight, j.chatID)
+	}
+	j.attempts++
+	now := time.Now()
+	retry := false
+	var rl *RateLimitError
+	switch {
+	case j.attempts >= maxAttempts:
+	case errors.As(err, &rl):
+		wait := rl.RetryAfter
+		if wait <= 0 {
+			wait = backoff(j.attempts)
+		}
+		// flood waits apply to the whole bot, not only to the chat
+		next := now.Add(wait)
+		if next.After(s.globalNext) {
+			s.globalNext = next
+		}
+		if j.free {
+			j.notBefore = next
+		} else if next.After(s.chatNext[j.chatID]) {
+			s.chatNext[j.chatID] = next
+		}
+		retry = true
+	case isTransient(err):
+		j.notBefore = now.Add(backoff(j.attempts))
+		retry = true
+	}
+	if retry {
+		s.push(j)
+	}
+	s.mu.Unlock()
+	s.signal()
+
+	if !retry {
+		j.done <- sendResult{data: data, err: err}
+	}
+}
+
+// prune forgets limits of chats that may be written to again, s.mu must be held
+func (s *scheduler) prune(now time.Time) {
+	for chatID, next := range s.chatNext {
+		if next.Before(now) {
+			delete(s.chatNext, chatID)
+		}
+	}
+}
+
+// chatInterval is the pause between messages to the chat, group ids are negative
+func (s *scheduler) chatInterval(chatID int) time.Duration {
+	if chatID < 0 {
+		return s.limits.Group
+	}
+	return s.limits.Private
+}
+
+func backoff(attempt int) time.Duration {
+	return retryBackoff << attempt
+}
+
+// isTransient is true for server errors and for network failures before the request was written,
+// a request that may have reached the API isn't repeated so the message isn't sent twice
+func isTransient(err error) bool {
+	var re *RequestError
+	if errors.As(err, &re) {
+		return re.Unsent
+	}
+	var ae *APIError
+	return errors.As(err, &ae) && ae.Code >= http.StatusInternalServerError
+}
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerFloodWait(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sends = make(map[string]time.Time)
+	)
+	limited := make(chan time.Time, 1)
+	s := newScheduler(SendLimits{}, func(method string, contentType string, body []byte) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := sends[string(body)]; !ok && string(body) == "first" {
+			sends[string(body)] = time.Now()
+			limited <- time.Now()
+			return nil, &RateLimitError{RetryAfter: 300 * time.Millisecond, err: &APIError{Code: http.StatusTooManyRequests}}
+		}
+		sends[string(body)] = time.Now()
+		return nil, nil
+	})
+
+	go s.do(1, PriorityInteractive, sendMessageMethod, "application/json", []byte("first"))
+	at := <-limited
+	if _, err := s.do(2, PriorityInteractive, sendMessageMethod, "application/json", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if d := sends["second"].Sub(at); d < 300*time.Millisecond {
+		t.Fatalf("another chat got a message %v after the flood wait started, want 300ms", d)
+	}
+}
+
+func TestSchedulerRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"unsent request", NewUnsentError(errors.New("connection refused")), 2},
+		{"request that may have reached the API", NewRequestError(errors.New("unexpected EOF")), 1},
+		{"server error", &APIError{Code: http.StatusBadGateway}, 2},
+		{"bad request", NewAPIError(http.StatusBadRequest, "chat not found", nil), 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			s := newScheduler(SendLimits{}, func(method string, contentType string, body []byte) ([]byte, error) {
+				calls++
+				if calls == 1 {
+					return nil, tt.err
+				}
+				return nil, nil
+			})
+
+			s.do(1, PriorityInteractive, sendMessageMethod, "application/json", nil)
+			if calls != tt.calls {
+				t.Fatalf("got %d calls, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package telegram
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// maxMessageLength is the Bot API limit of the message text in UTF-16 code units
+const maxMessageLength = 4096
+
+type messagePart struct {
+	text     string
+	entities []MessageEntity
+}
+
+// splitMessage cuts the text into parts that fit the limit. Parts end on line breaks,
+// then on spaces, and never inside an entity unless the entity itself is too long.
+// Entities are moved to the parts they belong to.
+func splitMessage(text string, entities []MessageEntity, limit int) []messagePart {
+	units := utf16.Encode([]rune(text))
+	if len(units) <= limit {
+		return []messagePart{{text: text, entities: entities}}
+	}
+
+	ranges := splitUnits(units, limit, func(pos int) bool { return insideEntity(entities, pos) })
+	parts := make([]messagePart, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, partOf(units, entities, r[0], r[1]))
+	}
+	return parts
+}
+
+// splitHTML cuts HTML text between tags and entities. The limit is applied to the markup,
+// so parts are a bit shorter than the limit after parsing.
+func splitHTML(text string, limit int) []messagePart {
+	units := utf16.Encode([]rune(text))
+	if len(units) <= limit {
+		return []messagePart{{text: text}}
+	}
+
+	blocked := htmlBlocked(units)
+	return markupParts(units, splitUnits(units, limit, func(pos int) bool { return blocked[pos] }))
+}
+
+// splitMarkdownV2 cuts MarkdownV2 text between escapes and entities like splitHTML does
+func splitMarkdownV2(text string, limit int) []messagePart {
+	units := utf16.Encode([]rune(text))
+	if len(units) <= limit {
+		return []messagePart{{text: text}}
+	}
+
+	blocked := markdownV2Blocked(units)
+	return markupParts(units, splitUnits(units, limit, func(pos int) bool { return blocked[pos] }))
+}
+
+// splitUnits returns [start, end) ranges of the parts, empty parts are left out
+func splitUnits(units []uint16, limit int, blocked func(pos int) bool) [][2]int {
+	ranges := make([][2]int, 0, len(units)/limit+1)
+	for start := 0; start < len(units); {
+		end, next := len(units), len(units)
+		if end-start > limit {
+			end, next = cutPoint(units, blocked, start, start+limit)
+		}
+		if strings.TrimSpace(string(utf16.Decode(units[start:end]))) != "" {
+			ranges = append(ranges, [2]int{start, end})
+		}
+		start = next
+	}
+	return ranges
+}
+
+// cutPoint finds where the part starting at start ends, and where the next one begins.
+// The separator between them is dropped.
+func cutPoint(units []uint16, blocked func(pos int) bool, start int, max int) (int, int) {
+	for _, sep := range []uint16{'\n', ' '} {
+		for i := max; i > start; i-- {
+			if units[i] == sep && !blocked(i) {
+				return i, i + 1
+			}
+		}
+	}
+	for i := max; i > start; i-- {
+		if !isLowSurrogate(units[i]) && !blocked(i) {
+			return i, i
+		}
+	}
+	// the entity is longer than the limit, it is split too
+	if isLowSurrogate(units[max]) {
+		max--
+	}
+	return max, max
+}
+
+func insideEntity(entities []MessageEntity, pos int) bool {
+	for _, e := range entities {
+		if e.Offset < pos && pos < e.Offset+e.Length {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlBlocked marks positions inside tags, inside entities like &amp; and between opening and closing tags
+func htmlBlocked(units []uint16) []bool {
+	blocked := make([]bool, len(units))
+	var inTag, inEntity bool
+	var depth, tagStart int
+	for i, u := range units {
+		blocked[i] = inTag || inEntity || depth > 0
+		switch {
+		case inTag && u == '>':
+			inTag = false
+			tag := string(utf16.Decode(units[tagStart+1 : i]))
+			switch {
+			case strings.HasPrefix(tag, "/"):
+				depth--
+			case !strings.HasSuffix(tag, "/"):
+				depth++
+			}
+		case inEntity && (u == ';' || u == ' ' || u == '\n'):
+			inEntity = false
+		case !inTag && u == '<':
+			inTag, tagStart = true, i
+		case !inTag && u == '&':
+			inEntity = true
+		}
+	}
+	return blocked
+}
+
+// markdownV2Blocked marks escaped characters, positions inside entities and inside their marks:
+// *bold*, _italic_, __underline__, ~strike~, ||spoiler||, `code`, ```pre``` and [links](url)
+func markdownV2Blocked(units []uint16) []bool {
+	blocked := make([]bool, len(units))
+	open := make(map[string]bool)
+	var code string
+	var link, url bool
+	// take consumes the mark starting at i, the rest of it can't be cut off
+	take := func(i int, n int) int {
+		for j := i + 1; j < i+n && j < len(units); j++ {
+			blocked[j] = true
+		}
+		return i + n - 1
+	}
+	toggle := func(mark string) {
+		if open[mark] {
+			delete(open, mark)
+		} else {
+			open[mark] = true
+		}
+	}
+
+	for i := 0; i < len(units); i++ {
+		blocked[i] = blocked[i] || code != "" || link || url || len(open) > 0
+		u := units[i]
+		switch {
+		case u == '\\':
+			i = take(i, 2)
+		case code != "":
+			if u == '`' && (code == "`" || markRun(units, i, '`') >= 3) {
+				i = take(i, len(code))
+				code = ""
+			}
+		case url:
+			url = u != ')'
+		case u == '`':
+			code = "`"
+			if markRun(units, i, '`') >= 3 {
+				code = "```"
+			}
+			i = take(i, len(code))
+		case u == '*' || u == '~':
+			toggle(string(rune(u)))
+		case u == '_' || u == '|':
+			mark := string(rune(u))
+			if markRun(units, i, u) >= 2 {
+				mark += mark
+			} else if u == '|' {
+				continue
+			}
+			toggle(mark)
+			i = take(i, len(mark))
+		case u == '[':
+			link = true
+		case u == ']' && link && i+1 < len(units) && units[i+1] == '(':
+			link, url = false, true
+			i = take(i, 2)
+		}
+	}
+	return blocked
+}
+
+// markRun counts the same marks in a row starting at i
+func markRun(units []uint16, i int, mark uint16) int {
+	n := 0
+	for i+n < len(units) && units[i+n] == mark {
+		n++
+	}
+	return n
+}
+
+func isLowSurrogate(u uint16) bool {
+	return u >= 0xDC00 && u <= 0xDFFF
+}
+
+// partOf takes units [start, end) and the entities clipped to them
+func partOf(units []uint16, entities []MessageEntity, start int, end int) messagePart {
+	p := messagePart{text: string(utf16.Decode(units[start:end]))}
+	for _, e := range entities {
+		from, to := e.Offset, e.Offset+e.Length
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		if from >= to {
+			continue
+		}
+		e.Offset, e.Length = from-start, to-from
+		p.entities = append(p.entities, e)
+	}
+	return p
+}
+
+func markupParts(units []uint16, ranges [][2]int) []messagePart {
+	parts := make([]messagePart, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, messagePart{text: string(utf16.Decode(units[r[0]:r[1]]))})
+	}
+	return parts
+}
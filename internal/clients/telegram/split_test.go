@@ -0,0 +1,41 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short text", "one two", 10, []string{"one two"}},
+		{"line breaks first", "one two\nthree", 10, []string{"one two", "three"}},
+		{"escaped space", `one\ two three`, 9, []string{`one\ two`, "three"}},
+		{"escaped backslash before a space", `one\\ two`, 6, []string{`one\\`, "two"}},
+		{"escape cut by force", `abcd\.efgh`, 5, []string{"abcd", `\.efg`, "h"}},
+		{"bold", "ab *one two* three", 10, []string{"ab", "*one two*", "three"}},
+		{"underline", "x __a b__ y", 8, []string{"x", "__a b__", "y"}},
+		{"spoiler", "x ||a b|| y", 8, []string{"x", "||a b||", "y"}},
+		{"marks inside code are text", "`*` one two", 8, []string{"`*` one", "two"}},
+		{"pre", "```\nab cd\n```\nef", 13, []string{"```\nab cd\n```", "ef"}},
+		{"link", "go [a b](u) c", 8, []string{"go", "[a b](u)", "c"}},
+		{"marks inside a link URL are text", "[a](u_v) b c", 10, []string{"[a](u_v) b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitMarkdownV2(tt.text, tt.limit)
+			got := make([]string, 0, len(parts))
+			for _, p := range parts {
+				got = append(got, p.text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
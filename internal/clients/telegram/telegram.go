@@ -2,45 +2,135 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"path"
-	"strings"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
+
+	"url-saver-bot/internal/format"
 )
 
 const (
-	tgHost            = "api.telegram.org"
-	getUpdatesMethod  = "getUpdates"
-	sendMessageMethod = "sendMessage"
-	showTagMessage    = "Here is all your tags:"
+	defaultBaseURL     = "https://api.telegram.org"
+	getUpdatesMethod   = "getUpdates"
+	sendMessageMethod  = "sendMessage"
+	sendDocumentMethod = "sendDocument"
+	editMessageMethod  = "editMessageText"
+	answerMethod       = "answerCallbackQuery"
+	// requestTimeout limits requests, getUpdates waits for the poll timeout on top of it
+	requestTimeout     = 30 * time.Second
+	defaultPollTimeout = 30 * time.Second
 )
 
 type Client struct {
-	host     string
-	basePath string
-	client   *http.Client
+	baseURL     url.URL
+	basePath    string
+	client      *http.Client
+	scheduler   *scheduler
+	priority    Priority
+	pollTimeout time.Duration
+	sendLimits  SendLimits
+	linkPreview bool
+}
+
+// Option changes the client made by NewClient
+type Option func(c *Client) error
+
+// WithBaseURL points the client to a self-hosted Bot API server or a fake one, e.g. http://localhost:8081
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("can't parse base URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("base URL must be an absolute http or https URL: %v", baseURL)
+		}
+		c.baseURL = *u
+		return nil
+	}
+}
+
+// WithHTTPClient makes requests with the given client, e.g. to set a proxy transport
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		c.client = client
+		return nil
+	}
+}
+
+// WithSendLimits changes the pauses between sent messages, e.g. to send without them in tests
+func WithSendLimits(limits SendLimits) Option {
+	return func(c *Client) error {
+		c.sendLimits = limits
+		return nil
+	}
+}
+
+// WithPollTimeout sets how long Updates wait for new updates
+func WithPollTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.pollTimeout = d
+		return nil
+	}
 }
 
-func NewClient(token string) *Client {
-	return &Client{
-		host:     tgHost,
-		basePath: newBasePath(token),
-		client:   &http.Client{},
+func NewClient(token string, opts ...Option) (*Client, error) {
+	base, _ := url.Parse(defaultBaseURL)
+	c := &Client{
+		baseURL:     *base,
+		basePath:    newBasePath(token),
+		client:      &http.Client{},
+		pollTimeout: defaultPollTimeout,
+		sendLimits:  DefaultSendLimits,
 	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	c.scheduler = newScheduler(c.sendLimits, func(method string, contentType string, body []byte) ([]byte, error) {
+		return c.post(method, contentType, bytes.NewReader(body), requestTimeout)
+	})
+	return c, nil
+}
+
+// WithPriority returns the client sending with the given priority through the same queue
+func (c *Client) WithPriority(p Priority) *Client {
+	cp := *c
+	cp.priority = p
+	return &cp
+}
+
+// WithLinkPreview returns the client showing previews of links in its messages, they are hidden by default
+func (c *Client) WithLinkPreview(on bool) *Client {
+	cp := *c
+	cp.linkPreview = on
+	return &cp
 }
 
 func newBasePath(token string) string {
 	return "bot" + token
 }
 
-func (c *Client) Updates(offset int, limit int) ([]Update, error) {
+// Updates returns new updates of the given types, or all types when allowedUpdates is empty.
+// It waits for the poll timeout when there are none.
+func (c *Client) Updates(offset int, limit int, allowedUpdates []string) ([]Update, error) {
 	u := UpdateRequest{
-		Offset: offset,
-		Limit:  limit,
+		Offset:         offset,
+		Limit:          limit,
+		Timeout:        int(c.pollTimeout / time.Second),
+		AllowedUpdates: allowedUpdates,
 	}
 
 	body, err := json.Marshal(u)
@@ -48,7 +138,7 @@ func (c *Client) Updates(offset int, limit int) ([]Update, error) {
 		return nil, fmt.Errorf("update request marshalling error: %w", err)
 	}
 
-	data, err := c.doRequest(getUpdatesMethod, body)
+	data, err := c.post(getUpdatesMethod, "application/json", bytes.NewReader(body), c.pollTimeout+requestTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -62,11 +152,57 @@ func (c *Client) Updates(offset int, limit int) ([]Update, error) {
 	return resp.Result, nil
 }
 
+// SendMessage sends the text, texts over the message limit go as several messages split on line breaks
 func (c *Client) SendMessage(chatID int, text string) error {
+	return c.SendFormatted(chatID, text, nil)
+}
+
+// SendFormatted sends the text with formatting entities, long texts are split between entities
+func (c *Client) SendFormatted(chatID int, text string, entities []MessageEntity) error {
+	return c.sendParts(chatID, splitMessage(text, entities, maxMessageLength), "")
+}
+
+// SendHTML sends the text in the HTML parse mode, long texts are split between tags
+func (c *Client) SendHTML(chatID int, html string) error {
+	return c.sendParts(chatID, splitHTML(html, maxMessageLength), string(format.ModeHTML))
+}
+
+// SendMarkdownV2 sends the text in the MarkdownV2 parse mode, long texts are split on line breaks
+func (c *Client) SendMarkdownV2(chatID int, text string) error {
+	return c.sendParts(chatID, splitMarkdownV2(text, maxMessageLength), string(format.ModeMarkdownV2))
+}
+
+// SendHTMLOrFile sends the HTML as a message, or as a plain .txt document when it is over the message limit
+func (c *Client) SendHTMLOrFile(chatID int, html string, fileName string, caption string) error {
+	if len(utf16.Encode([]rune(html))) <= maxMessageLength {
+		return c.SendHTML(chatID, html)
+	}
+	return c.SendDocument(chatID, fileName+".txt", []byte(format.StripHTML(html)), caption)
+}
+
+// SendTags sends the HTML text with the tag buttons, five in a row
+func (c *Client) SendTags(chatID int, text string, tags []InlineKeyboardButton) error {
+	return c.sendMarkup(chatID, text, createReplyMarkup(tags))
+}
+
+// SendKeyboard sends the HTML text with inline buttons under it
+func (c *Client) SendKeyboard(chatID int, text string, keyboard [][]InlineKeyboardButton) error {
+	return c.sendMarkup(chatID, text, &InlineKeyboardMarkup{InlineKeyboard: keyboard})
+}
+
+// sendMarkup sends the HTML text, when it is split the buttons go under the last part
+func (c *Client) sendMarkup(chatID int, text string, markup *InlineKeyboardMarkup) error {
+	parts := splitHTML(text, maxMessageLength)
+	if err := c.sendParts(chatID, parts[:len(parts)-1], string(format.ModeHTML)); err != nil {
+		return err
+	}
+
 	m := MessageRequest{
 		ChatID:             chatID,
-		Text:               text,
-		DisablePagePreview: true,
+		Text:               parts[len(parts)-1].text,
+		ParseMode:          string(format.ModeHTML),
+		DisablePagePreview: !c.linkPreview,
+		ReplyMarkup:        markup,
 	}
 
 	body, err := json.Marshal(m)
@@ -74,7 +210,7 @@ func (c *Client) SendMessage(chatID int, text string) error {
 		return fmt.Errorf("message marshalling error: %w", err)
 	}
 
-	_, err = c.doRequest(sendMessageMethod, body)
+	_, err = c.sendRequest(chatID, sendMessageMethod, body)
 	if err != nil {
 		return fmt.Errorf("send message error: %w", err)
 	}
@@ -82,30 +218,104 @@ func (c *Client) SendMessage(chatID int, text string) error {
 	return nil
 }
 
-func (c *Client) SendTags(chatID int, tags []string) error {
-	messageRequest := MessageRequest{
+// EditKeyboard replaces the HTML text and the buttons of the message, the text must fit one message
+func (c *Client) EditKeyboard(chatID int, messageID int, text string, keyboard [][]InlineKeyboardButton) error {
+	m := EditMessageRequest{
 		ChatID:             chatID,
-		Text:               showTagMessage,
-		DisablePagePreview: false,
-		ReplyMarkup:        createReplyMarkup(tags),
+		MessageID:          messageID,
+		Text:               text,
+		ParseMode:          string(format.ModeHTML),
+		DisablePagePreview: !c.linkPreview,
+		ReplyMarkup:        &InlineKeyboardMarkup{InlineKeyboard: keyboard},
 	}
 
-	body, err := json.Marshal(messageRequest)
+	body, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("message marshalling error: %w", err)
+	}
+
+	// the edit answers a button press, it doesn't wait for the chat's message slot
+	_, err = c.scheduler.doFree(chatID, editMessageMethod, "application/json", body)
+	if err != nil {
+		return fmt.Errorf("edit message error: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback tells the app the button press is handled, the text is shown as a notification
+func (c *Client) AnswerCallback(chatID int, callbackID string, text string) error {
+	body, err := json.Marshal(AnswerCallbackRequest{CallbackQueryID: callbackID, Text: text})
 	if err != nil {
 		return fmt.Errorf("can't marshal json: %w", err)
 	}
 
-	body, err = c.doRequest(sendMessageMethod, body)
+	// answers don't count toward the message limits
+	_, err = c.scheduler.doFree(chatID, answerMethod, "application/json", body)
 	if err != nil {
-		return fmt.Errorf("send message error: %w", err)
+		return fmt.Errorf("answer callback error: %w", err)
 	}
-	var message MessageResponse
-	err = json.Unmarshal(body, &message)
 
 	return nil
 }
 
-func createReplyMarkup(tags []string) *InlineKeyboardMarkup {
+// SendDocument uploads the file to the chat with an optional caption
+func (c *Client) SendDocument(chatID int, fileName string, data []byte, caption string) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	if err := w.WriteField("chat_id", strconv.Itoa(chatID)); err != nil {
+		return fmt.Errorf("can't write form: %w", err)
+	}
+	if caption != "" {
+		if err := w.WriteField("caption", caption); err != nil {
+			return fmt.Errorf("can't write form: %w", err)
+		}
+	}
+	part, err := w.CreateFormFile("document", fileName)
+	if err != nil {
+		return fmt.Errorf("can't write form: %w", err)
+	}
+	if _, err = part.Write(data); err != nil {
+		return fmt.Errorf("can't write form: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("can't write form: %w", err)
+	}
+
+	_, err = c.scheduler.do(chatID, c.priority, sendDocumentMethod, w.FormDataContentType(), body.Bytes())
+	if err != nil {
+		return fmt.Errorf("send document error: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) sendParts(chatID int, parts []messagePart, parseMode string) error {
+	for _, part := range parts {
+		m := MessageRequest{
+			ChatID:             chatID,
+			Text:               part.text,
+			Entities:           part.entities,
+			ParseMode:          parseMode,
+			DisablePagePreview: !c.linkPreview,
+		}
+
+		body, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("message marshalling error: %w", err)
+		}
+
+		_, err = c.sendRequest(chatID, sendMessageMethod, body)
+		if err != nil {
+			return fmt.Errorf("send message error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func createReplyMarkup(tags []InlineKeyboardButton) *InlineKeyboardMarkup {
 	countInRow := 5
 	countRows := int(math.Ceil(float64(len(tags)) / float64(countInRow)))
 	residual := len(tags)
@@ -116,12 +326,7 @@ func createReplyMarkup(tags []string) *InlineKeyboardMarkup {
 		countInRow = int(math.Min(float64(countInRow), float64(residual)))
 		buttonArray := make([]InlineKeyboardButton, 0, countInRow)
 		for j := 0; j < countInRow; j++ {
-			tag := strings.TrimSpace(nextTag())
-			button := InlineKeyboardButton{
-				Text:         tag,
-				CallbackData: tag,
-			}
-			buttonArray = append(buttonArray, button)
+			buttonArray = append(buttonArray, nextTag())
 		}
 		residual -= countInRow
 		buttons = append(buttons, buttonArray)
@@ -132,37 +337,66 @@ func createReplyMarkup(tags []string) *InlineKeyboardMarkup {
 	}
 }
 
-func tag(tags []string) func() string {
+func tag(tags []InlineKeyboardButton) func() InlineKeyboardButton {
 	i := -1
-	return func() string {
+	return func() InlineKeyboardButton {
 		i++
 		return tags[i]
 	}
 }
-func (c *Client) doRequest(method string, body []byte) ([]byte, error) {
-	u := url.URL{
-		Scheme: "https",
-		Host:   c.host,
-		Path:   path.Join(c.basePath, method),
-	}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewBuffer(body))
-	req.Header.Add("Content-Type", "application/json")
+// sendRequest queues the request to the chat within the rate limits
+func (c *Client) sendRequest(chatID int, method string, body []byte) ([]byte, error) {
+	return c.scheduler.do(chatID, c.priority, method, "application/json", body)
+}
+
+func (c *Client) post(method string, contentType string, body io.Reader, timeout time.Duration) ([]byte, error) {
+	u := c.baseURL
+	u.Path = path.Join("/", u.Path, c.basePath, method)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// the request is known not to reach the API until it is written
+	var written atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			written.Store(info.Err == nil)
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
 	if err != nil {
 		return nil, NewRequestError(err)
 	}
+	req.Header.Add("Content-Type", contentType)
 
 	resp, err := c.client.Do(req)
-	if err != nil {
+	if err != nil && !written.Load() {
+		return nil, NewUnsentError(err)
+	} else if err != nil {
 		return nil, NewRequestError(err)
 	}
 	defer resp.Body.Close()
 
-	body, err = io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, NewRequestError(err)
 	}
 
-	return body, nil
+	var r MessageResponse
+	if err = json.Unmarshal(data, &r); err != nil {
+		// proxies in front of the API answer with HTML on their own errors
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, NewAPIError(resp.StatusCode, resp.Status, nil)
+		}
+		return nil, NewRequestError(fmt.Errorf("can't decode response: %w", err))
+	}
+	if !r.OK {
+		code := r.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return nil, NewAPIError(code, r.Description, r.Parameters)
+	}
 
+	return data, nil
 }
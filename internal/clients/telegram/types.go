@@ -1,8 +1,13 @@
 package telegram
 
+import "encoding/json"
+
 type UpdateRequest struct {
 	Offset int `json:"offset"`
 	Limit  int `json:"limit"`
+	// Timeout is the long polling timeout in seconds
+	Timeout        int      `json:"timeout,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
 }
 type UpdateResponse struct {
 	Ok     bool     `json:"ok"`
@@ -18,21 +23,59 @@ type Update struct {
 type MessageRequest struct {
 	ChatID             int                   `json:"chat_id"`
 	Text               string                `json:"text"`
+	Entities           []MessageEntity       `json:"entities,omitempty"`
+	ParseMode          string                `json:"parse_mode,omitempty"`
+	DisablePagePreview bool                  `json:"disable_web_page_preview"`
+	ReplyMarkup        *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// EditMessageRequest replaces the text and the buttons of a sent message
+type EditMessageRequest struct {
+	ChatID             int                   `json:"chat_id"`
+	MessageID          int                   `json:"message_id"`
+	Text               string                `json:"text"`
+	ParseMode          string                `json:"parse_mode,omitempty"`
 	DisablePagePreview bool                  `json:"disable_web_page_preview"`
 	ReplyMarkup        *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
 }
 
+// AnswerCallbackRequest stops the button spinner, the text is shown as a notification
+type AnswerCallbackRequest struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+}
+
+// MessageResponse is the envelope of every Bot API response
 type MessageResponse struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description"`
-	Result      string `json:"result"`
-	ErrorCode   int    `json:"error_code"`
+	OK          bool                `json:"ok"`
+	Description string              `json:"description"`
+	Result      json.RawMessage     `json:"result"`
+	ErrorCode   int                 `json:"error_code"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+// ResponseParameters tell how a failed request can be repeated
+type ResponseParameters struct {
+	MigrateToChatID int `json:"migrate_to_chat_id"`
+	RetryAfter      int `json:"retry_after"`
+}
+
+// MessageEntity marks formatted text, offset and length are counted in UTF-16 code units
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url,omitempty"`
 }
 
 type IncomingMessage struct {
-	Chat Chat   `json:"chat"`
-	From User   `json:"from"`
-	Text string `json:"text"`
+	MessageID int             `json:"message_id"`
+	Chat      Chat            `json:"chat"`
+	From      User            `json:"from"`
+	Text      string          `json:"text"`
+	Entities  []MessageEntity `json:"entities,omitempty"`
+	// ReplyToMessage is the message this one replies to, its own reply isn't sent
+	ReplyToMessage *IncomingMessage `json:"reply_to_message,omitempty"`
 }
 
 type Chat struct {
@@ -42,6 +85,8 @@ type Chat struct {
 type User struct {
 	ID       int    `json:"id"`
 	UserName string `json:"username"`
+	// LanguageCode is the IETF tag of the user's app language, it is sent for private chats only
+	LanguageCode string `json:"language_code,omitempty"`
 }
 
 type InlineKeyboardMarkup struct {
@@ -54,6 +99,7 @@ type InlineKeyboardButton struct {
 }
 
 type CallbackQuery struct {
+	ID      string          `json:"id"`
 	From    User            `json:"from"`
 	Message IncomingMessage `json:"message"`
 	Data    string          `json:"data"`
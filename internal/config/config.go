@@ -4,12 +4,35 @@ import (
 	"flag"
 	"github.com/caarlos0/env/v9"
 	"log"
+	"time"
 )
 
 type config struct {
-	Token         string `env:"TELEGRAM_TOKEN"`
-	DatabaseDSN   string `env:"DATABASE_DSN"'`
+	Token         string        `env:"TELEGRAM_TOKEN"`
+	PollTimeout   time.Duration `env:"TELEGRAM_POLL_TIMEOUT" envDefault:"30s"`
+	APIURL        string        `env:"TELEGRAM_API_URL" envDefault:"https://api.telegram.org"`
+	DatabaseDSN   string        `env:"DATABASE_DSN" envDefault:"user=postgres password=123456 host=localhost port=5432 dbname=telegram"`
 	TagBufferSize int
+
+	FetchTimeout      time.Duration `env:"FETCH_TIMEOUT" envDefault:"20s"`
+	FetchMaxBodySize  int64         `env:"FETCH_MAX_BODY_SIZE" envDefault:"10485760"`
+	FetchMaxRedirects int           `env:"FETCH_MAX_REDIRECTS" envDefault:"5"`
+	UserAgent         string        `env:"FETCH_USER_AGENT" envDefault:"url-saver-bot/1.0 (+https://github.com/rvecwxqz/url-saver-bot)"`
+	AcceptLanguage    string        `env:"FETCH_ACCEPT_LANGUAGE" envDefault:"ru,en;q=0.8"`
+	HostConcurrency   int           `env:"FETCH_HOST_CONCURRENCY" envDefault:"2"`
+	HostDelay         time.Duration `env:"FETCH_HOST_DELAY" envDefault:"1s"`
+	FetchAllowNets    []string      `env:"FETCH_ALLOW_NETS" envSeparator:","`
+	FetchDenyNets     []string      `env:"FETCH_DENY_NETS" envSeparator:","`
+	RespectRobots     bool          `env:"FETCH_RESPECT_ROBOTS" envDefault:"true"`
+	RobotsTTL         time.Duration `env:"FETCH_ROBOTS_TTL" envDefault:"24h"`
+	ContentCacheTTL   time.Duration `env:"CONTENT_CACHE_TTL" envDefault:"24h"`
+	SnapshotDir       string        `env:"SNAPSHOT_DIR" envDefault:"./snapshots"`
+	SnapshotQuota     int64         `env:"SNAPSHOT_QUOTA" envDefault:"52428800"`
+	LinkCheckInterval time.Duration `env:"LINK_CHECK_INTERVAL" envDefault:"24h"`
+	// DigestSize is the number of unread links in a digest
+	DigestSize int `env:"DIGEST_SIZE" envDefault:"5"`
+	// ListsAsFile sends lists over the message limit as a .txt document instead of several messages
+	ListsAsFile bool `env:"LONG_LISTS_AS_FILE" envDefault:"false"`
 }
 
 var cfg *config
@@ -20,12 +43,14 @@ func NewConfig() *config {
 	}
 
 	cfg = &config{}
-	if err := env.Parse(&cfg); err != nil {
-		cfg.Token = *flag.String("t", "", "token for telegram bot")
-		cfg.DatabaseDSN = *flag.String("d", "user=postgres password=123456 host=localhost"+
-			" port=5432 dbname=telegram", "database DSN format: user=user password=pass host=host port=port dbname=name")
-		flag.Parse()
+	if err := env.Parse(cfg); err != nil {
+		log.Fatal(err)
 	}
+	// flags take priority over the environment
+	flag.StringVar(&cfg.Token, "t", cfg.Token, "token for telegram bot")
+	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "database DSN format: user=user password=pass host=host port=port dbname=name")
+	flag.Parse()
+
 	cfg.TagBufferSize = 20
 	if cfg.Token == "" {
 		log.Fatal("Empty token")
@@ -1,38 +1,45 @@
 package event_consumer
 
 import (
+	"context"
 	"log"
 	"sync"
 	"time"
 	"url-saver-bot/internal/events"
 )
 
+const errorPause = time.Second
+
 type Consumer struct {
 	fetcher   events.Fetcher
 	processor events.Processor
 	batchSize int
+	ctx       context.Context
 }
 
-func New(f events.Fetcher, p events.Processor, b int) Consumer {
+func New(ctx context.Context, f events.Fetcher, p events.Processor, b int) Consumer {
 	return Consumer{
 		fetcher:   f,
 		processor: p,
 		batchSize: b,
+		ctx:       ctx,
 	}
 }
 
+// Start handles events until the context is done
 func (c *Consumer) Start() error {
-	for {
+	for c.ctx.Err() == nil {
+		// the fetcher waits for new events itself, so there is no pause between polls
 		gotEvents, err := c.fetcher.Fetch(c.batchSize)
 		if err != nil {
 			log.Printf("[ERR] consumer: %v\n", err.Error())
 
-			time.Sleep(1 * time.Second)
+			// keeps the loop from spinning while the network is down
+			time.Sleep(errorPause)
 			continue
 		}
 
 		if len(gotEvents) == 0 {
-			time.Sleep(1 * time.Second)
 			continue
 		}
 
@@ -41,6 +48,7 @@ func (c *Consumer) Start() error {
 			continue
 		}
 	}
+	return nil
 }
 
 func (c *Consumer) handleEvents(e []events.Event) error {
@@ -50,7 +58,7 @@ func (c *Consumer) handleEvents(e []events.Event) error {
 		log.Printf("got event %v", event.Text)
 
 		go func(e events.Event) {
-			if err := c.processor.Process(event); err != nil {
+			if err := c.processor.Process(e); err != nil {
 				log.Printf("[ERR] error during proccessing event: %v", err.Error())
 			}
 			wg.Done()
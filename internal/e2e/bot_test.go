@@ -0,0 +1,497 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	tgClient "url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/storage"
+)
+
+// paragraphs makes an article text about the topic
+func paragraphs(topic string, n int) []string {
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, fmt.Sprintf("This is paragraph number %d of a long article about %v, "+
+			"it explains the subject in plain words so that the reader can follow every step of it.", i+1, topic))
+	}
+	return out
+}
+
+func TestSaveTagAndBrowseByTag(t *testing.T) {
+	h := newHarness(t)
+	h.classifier.setTag("soup", "cooking")
+	url := h.page("/soup", "Tomato soup", paragraphs("soup", 5)...)
+
+	h.send(url)
+	h.expectMessage("URL saved.")
+	page := h.waitStatus(url, storage.StatusTagged)
+	if page.Tags != "cooking" || page.TagSource != storage.TagSourceML {
+		t.Fatalf("got tag %q from %q, want cooking from the classifier", page.Tags, page.TagSource)
+	}
+
+	h.send("/show_tags")
+	h.expectKeyboard("Here is all your tags:", [][]tgClient.InlineKeyboardButton{
+		{{Text: "cooking", CallbackData: fmt.Sprintf("t:%d", page.ID)}},
+	})
+
+	h.press(fmt.Sprintf("t:%d", page.ID))
+	h.expectMessage("<b>cooking</b>:\n" + url)
+	h.expectNothing(200 * time.Millisecond)
+}
+
+func TestTagLookingLikeButtonData(t *testing.T) {
+	h := newHarness(t)
+	url := h.page("/later", "Later", paragraphs("patience", 3)...)
+
+	h.send(url)
+	h.expectMessage("URL saved.")
+	page := h.waitStatus(url, storage.StatusTagged)
+
+	h.send("/tag " + url + " rm:1")
+	h.expectMessage("Tag <b>rm:1</b> saved.")
+	h.send("/show_tags")
+	h.expectKeyboard("Here is all your tags:", [][]tgClient.InlineKeyboardButton{
+		{{Text: "rm:1", CallbackData: fmt.Sprintf("t:%d", page.ID)}},
+	})
+
+	h.press(fmt.Sprintf("t:%d", page.ID))
+	h.expectMessage("<b>rm:1</b>:\n" + url)
+
+	// a button sent before the tags had ids
+	h.press("cooking")
+	h.expectMessage("Your tags have changed, send /show_tags again.")
+}
+
+func TestSavingTwice(t *testing.T) {
+	h := newHarness(t)
+	url := h.page("/news", "News", paragraphs("news", 3)...)
+
+	h.send(url)
+	h.expectMessage("URL saved.")
+	h.send(url)
+	h.expectMessage("This URL is already saved.")
+}
+
+func TestShowAllAndGet(t *testing.T) {
+	h := newHarness(t)
+	first := h.page("/first", "First", paragraphs("rivers", 4)...)
+	second := h.page("/second", "Second", paragraphs("mountains", 4)...)
+
+	h.send(first)
+	h.expectMessage("URL saved.")
+	firstPage := h.waitStatus(first, storage.StatusTagged)
+	h.send(second)
+	h.expectMessage("URL saved.")
+	secondPage := h.waitStatus(second, storage.StatusTagged)
+
+	h.send("/show_all")
+	h.expectMessage(fmt.Sprintf("\n1. <a href=\"%v\">First</a> <b>other</b> <i>(1 min read, %d words)</i>"+
+		"\n2. <a href=\"%v\">Second</a> <b>other</b> <i>(1 min read, %d words)</i>",
+		first, firstPage.WordCount, second, secondPage.WordCount))
+
+	h.send("/get")
+	h.expectMessage(fmt.Sprintf("<b>First</b>\n%v\n<i>1 min read, %d words</i>", first, firstPage.WordCount))
+	h.waitRemoved(first)
+	h.send("/show_all")
+	h.expectMessage(fmt.Sprintf("\n1. <a href=\"%v\">Second</a> <b>other</b> <i>(1 min read, %d words)</i>",
+		second, secondPage.WordCount))
+
+	h.send("/get long")
+	h.expectMessage("No saved pages of this length.")
+}
+
+func TestRemove(t *testing.T) {
+	h := newHarness(t)
+	url := h.page("/old", "Old", paragraphs("history", 3)...)
+
+	h.send(url)
+	h.expectMessage("URL saved.")
+	h.waitStatus(url, storage.StatusTagged)
+
+	h.send("/remove " + url)
+	h.expectMessage("Link successfully removed.")
+	h.send("/show_all")
+	h.expectMessage("No saved pages.")
+}
+
+func TestUnknownCommand(t *testing.T) {
+	h := newHarness(t)
+
+	h.send("/dance")
+	h.expectMessage("Unknown command: <code>/dance</code>")
+}
+
+func TestLanguage(t *testing.T) {
+	h := newHarness(t)
+
+	h.sendIn("ru-RU", "/dance")
+	h.expectMessage("Неизвестная команда: <code>/dance</code>")
+
+	h.sendIn("ru", "/lang en")
+	h.expectMessage("Replies are in <b>English</b> now.")
+	h.sendIn("ru", "/dance")
+	h.expectMessage("Unknown command: <code>/dance</code>")
+
+	h.send("/lang")
+	h.expectKeyboard("Replies are in <b>English</b>. Pick another language:", [][]tgClient.InlineKeyboardButton{
+		{{Text: "English", CallbackData: "lang:en"}, {Text: "Русский", CallbackData: "lang:ru"}},
+	})
+	h.press("lang:ru")
+	h.expectMessage("Теперь ответы на языке: <b>Русский</b>.")
+	h.send("/retry")
+	h.expectMessage("Нет ссылок, которым не удалось расставить теги.")
+}
+
+func TestTitlesAreEscaped(t *testing.T) {
+	h := newHarness(t)
+	h.classifier.setTag("fish", "<i>food</i>")
+	url := h.page("/fish", "Fish &amp; &lt;chips&gt;", paragraphs("fish", 3)...)
+
+	h.send(url)
+	h.expectMessage("URL saved.")
+	h.waitStatus(url, storage.StatusTagged)
+
+	h.send("/show_all")
+	m := h.next()
+	want := fmt.Sprintf("<a href=\"%v\">Fish &amp; &lt;chips&gt;</a> <b>&lt;i&gt;food&lt;/i&gt;</b>", url)
+	if !strings.Contains(m.Text, want) {
+		t.Fatalf("got message %q, want it to contain %q", m.Text, want)
+	}
+	if m.ParseMode != "HTML" {
+		t.Fatalf("got parse mode %q, want HTML", m.ParseMode)
+	}
+}
+
+func TestLongListIsSplit(t *testing.T) {
+	h := newHarness(t)
+	const count = 80
+	created := time.Now()
+	for i := 0; i < count; i++ {
+		err := h.storage.Save(context.Background(), &storage.Page{
+			URL:      fmt.Sprintf("https://example.com/a/rather/long/path/to/make/the/list/longer/%03d", i),
+			UserName: userName,
+			Created:  created.Add(time.Duration(i) * time.Second),
+			Status:   storage.StatusTagged,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.send("/show_all")
+	var lines []string
+	for len(lines) < count {
+		m := h.next()
+		if len([]rune(m.Text)) > 4096 {
+			t.Fatalf("message of %d characters is over the limit", len([]rune(m.Text)))
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(m.Text), "\n")...)
+	}
+	for i, line := range lines {
+		url := fmt.Sprintf("https://example.com/a/rather/long/path/to/make/the/list/longer/%03d", i)
+		want := fmt.Sprintf("%d. <a href=\"%v\">%v</a>", i+1, url, url)
+		if line != want {
+			t.Fatalf("line %d is %q, want %q", i, line, want)
+		}
+	}
+	h.expectNothing(200 * time.Millisecond)
+}
+
+func TestSettings(t *testing.T) {
+	h := newHarness(t)
+
+	h.send("/settings")
+	menu := h.next()
+	if !strings.HasPrefix(menu.Text, "<b>Settings</b>") || menu.ReplyMarkup == nil {
+		t.Fatalf("got message %q, want the settings menu", menu.Text)
+	}
+	if got := menu.ReplyMarkup.InlineKeyboard[0][0]; got.Text != "Links at once: all" || got.CallbackData != "set:size" {
+		t.Fatalf("got button %+v", got)
+	}
+
+	h.pressOn(menu.MessageID, "set:get")
+	edit := h.expectMethod("editMessageText")
+	if edit.MessageID != menu.MessageID {
+		t.Fatalf("edited message %v, want %v", edit.MessageID, menu.MessageID)
+	}
+	if got := edit.ReplyMarkup.InlineKeyboard[1][0].Text; got != "/get: archives the link" {
+		t.Fatalf("got button %q after the press", got)
+	}
+	h.expectMethod("answerCallbackQuery")
+
+	h.send("/settings tz Europe/Berlin")
+	if m := h.next(); m.ReplyMarkup == nil || m.ReplyMarkup.InlineKeyboard[7][0].Text != "Time zone: Europe/Berlin" {
+		t.Fatalf("got message %q with keyboard %+v, want the new time zone", m.Text, m.ReplyMarkup)
+	}
+	// button labels are plain text, nothing in them is escaped
+	h.send("/settings tz Etc/GMT+3")
+	if m := h.next(); m.ReplyMarkup == nil || m.ReplyMarkup.InlineKeyboard[7][0].Text != "Time zone: Etc/GMT+3" {
+		t.Fatalf("got message %q with keyboard %+v, want the new time zone", m.Text, m.ReplyMarkup)
+	}
+	h.send("/settings tz Mars/Olympus")
+	h.expectMessage("Format: <code>/settings size 15</code>, <code>/settings hour 22</code> or " +
+		"<code>/settings tz Asia/Tbilisi</code>. The page size is up to 100, 0 lists all links at once.")
+
+	url := h.page("/kept", "Kept", paragraphs("rivers", 3)...)
+	h.send(url)
+	h.expectMessage("URL saved.")
+	h.waitStatus(url, storage.StatusTagged)
+	h.send("/get")
+	h.next()
+	h.send("/show_all")
+	h.expectMessage("No saved pages.")
+	if p, err := h.storage.Get(context.Background(), url, userName); err != nil || !p.Archived {
+		t.Fatalf("got page %+v, %v, want it archived", p, err)
+	}
+}
+
+func TestAutoTagOffSkipsClassifier(t *testing.T) {
+	h := newHarness(t)
+	h.classifier.mu.Lock()
+	h.classifier.down = true
+	h.classifier.mu.Unlock()
+
+	h.send("/settings")
+	menu := h.next()
+	h.pressOn(menu.MessageID, "set:autotag")
+	h.expectMethod("editMessageText")
+	h.expectMethod("answerCallbackQuery")
+
+	url := h.page("/notes", "Notes", paragraphs("notes", 3)...)
+	h.send(url)
+	h.expectMessage("URL saved.")
+	page := h.waitStatus(url, storage.StatusTagged)
+	if page.Title != "Notes" || page.Tags != "" {
+		t.Fatalf("got title %q and tag %q, want the title without a tag", page.Title, page.Tags)
+	}
+
+	h.classifier.mu.Lock()
+	defer h.classifier.mu.Unlock()
+	if h.classifier.calls != 0 {
+		t.Fatalf("got %d predictions, want none", h.classifier.calls)
+	}
+}
+
+func TestRetagAll(t *testing.T) {
+	h := newHarness(t)
+	var urls []string
+	for _, topic := range []string{"lakes", "hills"} {
+		url := h.page("/"+topic, topic, paragraphs(topic, 3)...)
+		h.send(url)
+		h.expectMessage("URL saved.")
+		h.waitStatus(url, storage.StatusTagged)
+		urls = append(urls, url)
+	}
+	h.send("/tag " + urls[1] + " mine")
+	h.expectMessage("Tag <b>mine</b> saved.")
+
+	h.send("/retag all")
+	h.expectMessage("Links sent for retagging: <b>1</b>")
+
+	h.send("/settings")
+	menu := h.next()
+	h.pressOn(menu.MessageID, "set:autotag")
+	h.expectMethod("editMessageText")
+	h.expectMethod("answerCallbackQuery")
+	h.send("/retag all")
+	h.expectMessage("Auto-tagging is off, turn it on in /settings to retag links.")
+}
+
+func TestPaging(t *testing.T) {
+	h := newHarness(t)
+	h.send("/settings size 2")
+	h.next()
+
+	var urls []string
+	for _, topic := range []string{"rivers", "mountains", "forests"} {
+		url := h.page("/"+topic, topic, paragraphs(topic, 3)...)
+		h.send(url)
+		h.expectMessage("URL saved.")
+		h.waitStatus(url, storage.StatusTagged)
+		urls = append(urls, url)
+	}
+
+	h.send("/show_all")
+	m := h.next()
+	if !strings.HasSuffix(m.Text, "\n<i>1–2 of 3</i>") || strings.Contains(m.Text, urls[2]) {
+		t.Fatalf("got message %q, want the first 2 links", m.Text)
+	}
+	if m.ReplyMarkup == nil || m.ReplyMarkup.InlineKeyboard[0][0].CallbackData != "more:2:" {
+		t.Fatalf("got keyboard %+v, want the more button", m.ReplyMarkup)
+	}
+
+	h.pressOn(m.MessageID, "more:2:")
+	h.expectMethod("answerCallbackQuery")
+	m = h.next()
+	if !strings.HasPrefix(m.Text, "\n3. <a href=\""+urls[2]+"\">") || m.ReplyMarkup != nil {
+		t.Fatalf("got message %q, want the last link without buttons", m.Text)
+	}
+
+	h.press("more:3:")
+	h.expectMethod("answerCallbackQuery")
+	h.expectMessage("There are no more links in the list.")
+}
+
+func TestDigest(t *testing.T) {
+	h := newHarness(t)
+	h.classifier.setTag("cakes", "food")
+	h.send("/settings")
+	menu := h.next()
+	h.pressOn(menu.MessageID, "set:digest")
+	h.expectMethod("editMessageText")
+	h.expectMethod("answerCallbackQuery")
+
+	var pages []storage.Page
+	for _, topic := range []string{"rivers", "cakes", "mountains"} {
+		url := h.page("/"+topic, topic, paragraphs(topic, 3)...)
+		h.send(url)
+		h.expectMessage("URL saved.")
+		pages = append(pages, h.waitStatus(url, storage.StatusTagged))
+	}
+	line := func(n int, p storage.Page) string {
+		return fmt.Sprintf("\n%d. <a href=\"%v\">%v</a> <i>(1 min read, %d words)</i>", n, p.URL, p.Title, p.WordCount)
+	}
+	buttons := func(n int, p storage.Page) []tgClient.InlineKeyboardButton {
+		return []tgClient.InlineKeyboardButton{
+			{Text: fmt.Sprintf("Read %d", n), CallbackData: fmt.Sprintf("read:%d", p.ID)},
+			{Text: fmt.Sprintf("Archive %d", n), CallbackData: fmt.Sprintf("arch:%d", p.ID)},
+			{Text: fmt.Sprintf("Snooze %d", n), CallbackData: fmt.Sprintf("snooze:%d", p.ID)},
+		}
+	}
+
+	// the daily digest is due at 9:00 UTC and is sent once for the slot
+	morning := time.Now().UTC().Truncate(24 * time.Hour).Add(9*time.Hour + 5*time.Minute)
+	for _, now := range []time.Time{morning.Add(-10 * time.Minute), morning, morning.Add(time.Minute)} {
+		if err := h.digester.SendDue(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.expectKeyboard("<b>Links you haven't read yet</b>\n\n<b>other</b>"+line(1, pages[0])+line(2, pages[2])+
+		"\n\n<b>food</b>"+line(3, pages[1]),
+		[][]tgClient.InlineKeyboardButton{buttons(1, pages[0]), buttons(2, pages[2]), buttons(3, pages[1])})
+	h.expectNothing(300 * time.Millisecond)
+
+	h.press(fmt.Sprintf("read:%d", pages[0].ID))
+	if m := h.expectMethod("answerCallbackQuery"); m.Text != "Marked as read." {
+		t.Fatalf("got answer %q", m.Text)
+	}
+	h.waitRemoved(pages[0].URL)
+	h.press(fmt.Sprintf("snooze:%d", pages[1].ID))
+	h.expectMethod("answerCallbackQuery")
+	h.press(fmt.Sprintf("arch:%d", pages[0].ID))
+	if m := h.expectMethod("answerCallbackQuery"); m.Text != "This URL is not saved." {
+		t.Fatalf("got answer %q for a removed page", m.Text)
+	}
+
+	// snoozed pages skip the next digests, a digest past its hour waits for the next day
+	if err := h.digester.SendDue(morning.Add(22 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectNothing(300 * time.Millisecond)
+	if err := h.digester.SendDue(morning.Add(24 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectKeyboard("<b>Links you haven't read yet</b>\n\n<b>other</b>"+line(1, pages[2]),
+		[][]tgClient.InlineKeyboardButton{buttons(1, pages[2])})
+}
+
+func TestScheduledMessagesInAppLanguage(t *testing.T) {
+	h := newHarness(t)
+	url := h.page("/tea", "Tea", paragraphs("tea", 3)...)
+	h.sendIn("ru", url)
+	h.expectMessage("Ссылка сохранена.")
+	h.waitStatus(url, storage.StatusTagged)
+
+	h.sendIn("ru", "/settings")
+	menu := h.next()
+	h.pressIn("ru", menu.MessageID, "set:digest")
+	h.expectMethod("editMessageText")
+	h.expectMethod("answerCallbackQuery")
+	h.sendIn("ru", "/remind "+url+" через 2 часа")
+	h.next()
+
+	// the user never picked a language with /lang
+	now := time.Now()
+	if err := h.reminders.SendDue(now.Add(3 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectMessage("Вы просили напомнить об этой ссылке:\n<b>Tea</b>\n" + url)
+	if err := h.digester.SendDue(time.Now().UTC().Truncate(24 * time.Hour).Add(9*time.Hour + 5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if m := h.next(); !strings.HasPrefix(m.Text, "<b>Ссылки, которые вы ещё не прочитали</b>") {
+		t.Fatalf("got digest %q, want it in Russian", m.Text)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	h := newHarness(t)
+	url := h.page("/bread", "Bread", paragraphs("bread", 3)...)
+	h.send(url)
+	h.expectMessage("URL saved.")
+	h.waitStatus(url, storage.StatusTagged)
+
+	h.send("/remind " + url + " whenever")
+	if m := h.next(); !strings.HasPrefix(m.Text, "Format: <code>/remind link when</code>") {
+		t.Fatalf("got message %q, want the format", m.Text)
+	}
+	h.send("/remind " + url + " in 2h")
+	if m := h.next(); !strings.HasPrefix(m.Text, "I'll remind you on <b>") || !strings.HasSuffix(m.Text, "</b> (UTC).") {
+		t.Fatalf("got message %q", m.Text)
+	}
+
+	now := time.Now()
+	if err := h.reminders.SendDue(now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectNothing(300 * time.Millisecond)
+	if err := h.reminders.SendDue(now.Add(3 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	m := h.expectMessage("You asked me to remind you of this link:\n<b>Bread</b>\n" + url)
+	buttons := m.ReplyMarkup.InlineKeyboard
+	if len(buttons) != 2 || len(buttons[0]) != 3 || buttons[0][0].Text != "In an hour" || buttons[1][0].Text != "Done" {
+		t.Fatalf("got keyboard %+v", buttons)
+	}
+	if err := h.reminders.SendDue(now.Add(4 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectNothing(300 * time.Millisecond)
+
+	// snoozing for an hour makes it due again, done removes it
+	h.press(buttons[0][0].CallbackData)
+	if a := h.expectMethod("answerCallbackQuery"); !strings.HasPrefix(a.Text, "I'll remind you again on ") {
+		t.Fatalf("got answer %q", a.Text)
+	}
+	if err := h.reminders.SendDue(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectMessage("You asked me to remind you of this link:\n<b>Bread</b>\n" + url)
+	h.press(buttons[1][0].CallbackData)
+	if a := h.expectMethod("answerCallbackQuery"); a.Text != "Done." {
+		t.Fatalf("got answer %q", a.Text)
+	}
+	h.press(buttons[0][0].CallbackData)
+	if a := h.expectMethod("answerCallbackQuery"); a.Text != "This reminder is already done." {
+		t.Fatalf("got answer %q", a.Text)
+	}
+	h.press(buttons[1][0].CallbackData)
+	if a := h.expectMethod("answerCallbackQuery"); a.Text != "This reminder is already done." {
+		t.Fatalf("got answer %q", a.Text)
+	}
+
+	// a reply takes the link from the replied message, the time is in the user's time zone
+	h.send("/settings tz Asia/Tokyo")
+	h.next()
+	h.sendReply("Look at "+url, "/remind завтра в 9:00")
+	if m := h.next(); !strings.HasSuffix(m.Text, " 09:00</b> (JST).") {
+		t.Fatalf("got message %q", m.Text)
+	}
+	if err := h.reminders.SendDue(now.Add(48 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.expectMessage("You asked me to remind you of this link:\n<b>Bread</b>\n" + url)
+}
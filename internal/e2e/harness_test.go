@@ -0,0 +1,296 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"url-saver-bot/internal/archive"
+	"url-saver-bot/internal/archive/fs"
+	tgClient "url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/clients/telegram/fake"
+	eventConsumer "url-saver-bot/internal/consumer/event-consumer"
+	"url-saver-bot/internal/events/telegram"
+	"url-saver-bot/internal/ml/parser"
+	pb "url-saver-bot/internal/proto"
+	"url-saver-bot/internal/storage"
+	"url-saver-bot/internal/storage/memory"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	chatID   = 1001
+	userName = "alice"
+	// waitTimeout is how long a step waits for the bot, tagging goes through a fetch and a prediction
+	waitTimeout = 10 * time.Second
+)
+
+// harness runs the bot against the fake Bot API, the in-memory storage, a fake classifier
+// and a local site, so scenarios need no network
+type harness struct {
+	t          *testing.T
+	bot        *fake.Server
+	storage    *memory.MemoryStorage
+	classifier *fakeClassifier
+	site       *httptest.Server
+	digester   *telegram.Digester
+	reminders  *telegram.Reminders
+	pages      sync.Map
+	// seen is the number of sent messages the scenario has checked
+	seen int
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h := &harness{
+		t:          t,
+		bot:        fake.NewServer(),
+		storage:    memory.NewMemoryStorage(),
+		classifier: &fakeClassifier{tags: make(map[string]string)},
+	}
+	h.site = httptest.NewServer(http.HandlerFunc(h.serve))
+
+	client, err := tgClient.NewClient(fake.Token,
+		tgClient.WithBaseURL(h.bot.URL()),
+		tgClient.WithPollTimeout(time.Second),
+		tgClient.WithSendLimits(tgClient.SendLimits{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher, err := parser.NewFetcher(parser.FetcherConfig{
+		Timeout:         5 * time.Second,
+		MaxBodySize:     1 << 20,
+		MaxRedirects:    5,
+		UserAgent:       "url-saver-bot-test",
+		HostConcurrency: 4,
+		// the local site is on loopback, which is blocked by default
+		AllowNets: []string{"127.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobStore, err := fs.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiver := archive.New(blobStore, h.storage, 1<<20)
+	worker := parser.NewTagWorker(ctx, h.storage, fetcher, archiver, h.classifier, 1, time.Hour)
+	processor := telegram.New(ctx, client, h.storage, worker, archiver, false)
+	// the digester and reminders aren't started, scenarios call SendDue with the time they need
+	h.digester = telegram.NewDigester(ctx, client, h.storage, 5)
+	h.reminders = telegram.NewReminders(ctx, client, h.storage)
+
+	consumer := eventConsumer.New(ctx, processor, processor, 100)
+	done := make(chan struct{})
+	go func() {
+		_ = consumer.Start()
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		h.site.Close()
+		h.bot.Close()
+	})
+	return h
+}
+
+// page publishes an article on the local site and returns its URL
+func (h *harness) page(path string, title string, paragraphs ...string) string {
+	var body strings.Builder
+	for _, p := range paragraphs {
+		body.WriteString("<p>" + p + "</p>\n")
+	}
+	h.pages.Store(path, fmt.Sprintf("<!doctype html><html lang=\"en\"><head><title>%v</title></head>"+
+		"<body><article><h1>%v</h1>\n%v</article></body></html>", title, title, body.String()))
+	return h.site.URL + path
+}
+
+func (h *harness) serve(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.pages.Load(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+// send is a message from the user
+func (h *harness) send(text string) {
+	h.bot.PushMessage(chatID, userName, text)
+}
+
+// sendIn is a message from the user whose Telegram app is in the language
+func (h *harness) sendIn(languageCode string, text string) {
+	h.bot.PushUpdate(tgClient.Update{Message: &tgClient.IncomingMessage{
+		Chat: tgClient.Chat{ID: chatID},
+		From: tgClient.User{ID: chatID, UserName: userName, LanguageCode: languageCode},
+		Text: text,
+	}})
+}
+
+// sendReply is a message replying to the given text
+func (h *harness) sendReply(replyTo string, text string) {
+	h.bot.PushUpdate(tgClient.Update{Message: &tgClient.IncomingMessage{
+		Chat:           tgClient.Chat{ID: chatID},
+		From:           tgClient.User{ID: chatID, UserName: userName},
+		Text:           text,
+		ReplyToMessage: &tgClient.IncomingMessage{Chat: tgClient.Chat{ID: chatID}, Text: replyTo},
+	}})
+}
+
+// press is a press of the inline button with the given callback data
+func (h *harness) press(data string) {
+	h.bot.PushCallback(chatID, userName, data)
+}
+
+// pressOn is a press of the inline button under the sent message
+func (h *harness) pressOn(messageID int, data string) {
+	h.bot.PushCallbackOn(chatID, userName, messageID, data)
+}
+
+// pressIn is a press of the inline button under the message by the user whose Telegram app is in the language
+func (h *harness) pressIn(languageCode string, messageID int, data string) {
+	h.bot.PushUpdate(tgClient.Update{CallbackQuery: &tgClient.CallbackQuery{
+		From:    tgClient.User{ID: chatID, UserName: userName, LanguageCode: languageCode},
+		Message: tgClient.IncomingMessage{MessageID: messageID, Chat: tgClient.Chat{ID: chatID}},
+		Data:    data,
+	}})
+}
+
+// next waits for the next message the bot sends
+func (h *harness) next() fake.Sent {
+	h.t.Helper()
+	sent := h.bot.WaitSent(h.seen+1, waitTimeout)
+	if len(sent) <= h.seen {
+		h.t.Fatalf("the bot sent nothing, last messages: %v", texts(sent))
+	}
+	h.seen++
+	return sent[h.seen-1]
+}
+
+// expectMessage checks the text of the next message
+func (h *harness) expectMessage(text string) fake.Sent {
+	h.t.Helper()
+	m := h.next()
+	if m.Text != text {
+		h.t.Fatalf("got message %q, want %q", m.Text, text)
+	}
+	return m
+}
+
+// expectKeyboard checks the text of the next message and the text and data of its buttons
+func (h *harness) expectKeyboard(text string, buttons [][]tgClient.InlineKeyboardButton) {
+	h.t.Helper()
+	m := h.expectMessage(text)
+	if m.ReplyMarkup == nil {
+		h.t.Fatalf("message %q has no keyboard", text)
+	}
+	if !reflect.DeepEqual(m.ReplyMarkup.InlineKeyboard, buttons) {
+		h.t.Fatalf("got keyboard %+v, want %+v", m.ReplyMarkup.InlineKeyboard, buttons)
+	}
+}
+
+// expectMethod checks the Bot API method of the next request
+func (h *harness) expectMethod(method string) fake.Sent {
+	h.t.Helper()
+	m := h.next()
+	if m.Method != method {
+		h.t.Fatalf("got %v %q, want %v", m.Method, m.Text, method)
+	}
+	return m
+}
+
+// expectNothing checks that the bot sends nothing more for a while
+func (h *harness) expectNothing(wait time.Duration) {
+	h.t.Helper()
+	if sent := h.bot.WaitSent(h.seen+1, wait); len(sent) > h.seen {
+		h.t.Fatalf("unexpected message %q", sent[h.seen].Text)
+	}
+}
+
+// waitStatus waits until the saved page gets the status
+func (h *harness) waitStatus(url string, status storage.Status) storage.Page {
+	h.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		p, err := h.storage.Get(context.Background(), url, userName)
+		if err == nil && p.Status == status {
+			return *p
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("page %v didn't get status %v: %+v, %v", url, status, p, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitRemoved waits until the page is gone from the storage, the bot answers before it removes pages
+func (h *harness) waitRemoved(url string) {
+	h.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		if _, err := h.storage.Get(context.Background(), url, userName); err != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("page %v wasn't removed", url)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func texts(sent []fake.Sent) []string {
+	out := make([]string, 0, len(sent))
+	for _, s := range sent {
+		out = append(out, s.Text)
+	}
+	return out
+}
+
+// fakeClassifier tags texts containing a keyword with its tag, a down one fails every prediction
+type fakeClassifier struct {
+	mu    sync.Mutex
+	tags  map[string]string
+	calls int
+	down  bool
+}
+
+func (c *fakeClassifier) setTag(keyword string, tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tags[keyword] = tag
+}
+
+func (c *fakeClassifier) Predict(ctx context.Context, in *pb.PredictRequest, opts ...grpc.CallOption) (*pb.PredictResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.down {
+		return nil, status.Error(codes.Unavailable, "classifier is down")
+	}
+	text := strings.ToLower(in.Text)
+	for keyword, tag := range c.tags {
+		if strings.Contains(text, keyword) {
+			return &pb.PredictResponse{Prediction: tag, Classifier: "fake", ModelVersion: "1"}, nil
+		}
+	}
+	return &pb.PredictResponse{Prediction: "other", Classifier: "fake", ModelVersion: "1"}, nil
+}
+
+func (c *fakeClassifier) Info(ctx context.Context, in *pb.InfoRequest, opts ...grpc.CallOption) (*pb.InfoResponse, error) {
+	return &pb.InfoResponse{Classifier: "fake", ModelVersion: "1"}, nil
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 	"url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/i18n"
+	"url-saver-bot/internal/ml/parser"
 	"url-saver-bot/internal/storage"
 )
 
@@ -19,75 +22,162 @@ const (
 	removeCmd    = "/remove"
 	showTags     = "/show_tags"
 	showAllByTag = "/show_all_by_tag"
+	retryCmd     = "/retry"
+	retagCmd     = "/retag"
+	tagCmd       = "/tag"
+	snapshotCmd  = "/snapshot"
+	brokenCmd    = "/broken"
+	langCmd      = "/lang"
+	settingsCmd  = "/settings"
+	remindCmd    = "/remind"
 )
 
-func (p *TgProcessor) doCmd(text string, chatID int, username string) error {
+const retagAll = "all"
+
+const listFileName = "links"
+
+const (
+	typeFilter     = "type:"
+	languageFilter = "lang:"
+	shortFilter    = "short"
+	longFilter     = "long"
+	// short reads fit a break, long ones need an evening
+	shortReadingTime = 10 * time.Minute
+	longReadingTime  = 20 * time.Minute
+)
+
+// callback data of inline buttons is limited to 64 bytes, so buttons refer to pages by id
+const (
+	callbackSeparator = ":"
+	removeAction      = "rm"
+	snapshotAction    = "snap"
+	tagAction         = "tag"
+	langAction        = "lang"
+	settingAction     = "set"
+	moreAction        = "more"
+	readAction        = "read"
+	archiveAction     = "arch"
+	snoozeAction      = "snooze"
+	remindAction      = "remind"
+	doneAction        = "done"
+	showTagAction     = "t"
+	maxCallbackData   = 64
+	maxBrokenButtons  = 20
+)
+
+func (p *TgProcessor) doCmd(text string, chatID int, lang i18n.Lang, username string) error {
 	text = strings.TrimSpace(text)
 
 	log.Printf("got new command '%v' from '%v", text, username)
 
 	if isURL(text) {
-		return p.addPage(text, username, chatID)
+		return p.addPage(text, username, chatID, lang)
 	}
 
 	cmd := strings.Split(text, " ")[0]
 
 	switch cmd {
 	case getCmd:
-		return p.getPage(username, chatID)
+		return p.getPage(username, chatID, lang, text)
 	case helpCmd:
-		return p.sendHelp(chatID)
+		return p.sendHelp(chatID, lang)
 	case startCmd:
-		return p.start(chatID)
+		return p.start(chatID, lang)
 	case showAllCmd:
-		return p.showAll(chatID, username)
+		return p.showAll(chatID, lang, username, text)
 	case removeCmd:
-		return p.removePage(username, chatID, text)
+		return p.removePage(username, chatID, lang, text)
 	case showTags:
-		return p.showTags(username, chatID)
+		return p.showTags(username, chatID, lang)
 	case showAllByTag:
-		return p.showAllByTag(username, chatID, text)
+		return p.showAllByTag(username, chatID, lang, text)
+	case retryCmd:
+		return p.retry(username, chatID, lang, text)
+	case retagCmd:
+		return p.retag(username, chatID, lang, text)
+	case tagCmd:
+		return p.setTag(username, chatID, lang, text)
+	case snapshotCmd:
+		return p.sendSnapshot(username, chatID, lang, text)
+	case brokenCmd:
+		return p.showBroken(username, chatID, lang)
+	case langCmd:
+		return p.setLanguage(username, chatID, lang, text)
+	case settingsCmd:
+		return p.showSettings(username, chatID, lang, text)
+	case remindCmd:
+		return p.remind(username, chatID, lang, text)
 	default:
-		return p.tgClient.SendMessage(chatID, fmt.Sprintf("%v: %v", unknownCommandMessage, cmd))
+		return p.reply(chatID, lang, "unknown_command", cmd)
 	}
 }
 
-func (p *TgProcessor) addPage(pageURL string, userName string, chatID int) error {
-	sendMsg := NewMessageSender(chatID, p.tgClient)
-
+func (p *TgProcessor) addPage(pageURL string, userName string, chatID int, lang i18n.Lang) error {
 	page := &storage.Page{
 		URL:      pageURL,
 		Tags:     "",
 		UserName: userName,
 		Created:  time.Now(),
+		Status:   storage.StatusPending,
 	}
 
-	err := p.storage.Save(p.ctx, page)
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	err = p.storage.Save(p.ctx, page)
 	var e *storage.AlreadyExistsError
 	if errors.As(err, &e) {
-		return sendMsg(alreadyExistsMessage)
+		return p.reply(chatID, lang, "already_exists", nil)
 	} else if err != nil {
 		return fmt.Errorf("can't save page: %w", err)
 	}
 
-	p.tagWorker.AppendPage(*page)
+	if settings.AutoTag {
+		p.tagWorker.AppendPage(*page)
+	} else {
+		p.tagWorker.AppendUntagged(*page)
+	}
 
-	return sendMsg(SavedMessage)
+	return p.reply(chatID, lang, "saved", nil)
 }
 
-func (p *TgProcessor) getPage(userName string, chatID int) error {
-	page, err := p.storage.Pick(p.ctx, userName)
+// getPage sends the first saved page and removes or archives it,
+// "short" or "long" after the command pick the first page of that length
+func (p *TgProcessor) getPage(userName string, chatID int, lang i18n.Lang, text string) error {
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	f := parseFilter(strings.Fields(text)[1:])
+	page, err := p.storage.Pick(p.ctx, userName, f)
 	var e *storage.NoResultError
 	if errors.As(err, &e) {
-		return p.tgClient.SendMessage(chatID, NoSavedPagesMessage)
+		if f.MinReadingTime > 0 || f.MaxReadingTime > 0 {
+			return p.reply(chatID, lang, "no_pages_of_length", nil)
+		}
+		return p.reply(chatID, lang, "no_saved_pages", nil)
 	} else if err != nil {
 		return fmt.Errorf("can't pick URL from storage: %w", err)
 	}
 
-	err = p.tgClient.SendMessage(chatID, page.URL)
+	message, err := templates.Render(lang, "picked_page", page)
+	if err != nil {
+		return err
+	}
+	err = p.tgClient.WithLinkPreview(settings.LinkPreview).SendHTML(chatID, message)
 	if err != nil {
 		return fmt.Errorf("can't send message: %w", err)
 	}
+
+	if settings.ArchiveOnGet {
+		if err = p.storage.Archive(p.ctx, page); err != nil {
+			return fmt.Errorf("can't archive page: %w", err)
+		}
+		return nil
+	}
 	if err = p.storage.Remove(p.ctx, page); err != nil {
 		return fmt.Errorf("can't remove page: %w", err)
 	}
@@ -95,14 +185,14 @@ func (p *TgProcessor) getPage(userName string, chatID int) error {
 	return nil
 }
 
-func (p *TgProcessor) removePage(userName string, chatID int, text string) error {
+func (p *TgProcessor) removePage(userName string, chatID int, lang i18n.Lang, text string) error {
 	splitArray := strings.Split(text, " ")
 	if len(splitArray) < 2 {
-		return p.tgClient.SendMessage(chatID, noLinkMessage)
+		return p.reply(chatID, lang, "no_link", nil)
 	}
 	URL := splitArray[1]
 	if !isURL(URL) {
-		return p.tgClient.SendMessage(chatID, noLinkMessage)
+		return p.reply(chatID, lang, "no_link", nil)
 	}
 
 	page := storage.Page{
@@ -113,69 +203,544 @@ func (p *TgProcessor) removePage(userName string, chatID int, text string) error
 		return fmt.Errorf("can't remove page: %w", err)
 	}
 
-	return p.tgClient.SendMessage(chatID, pageRemovedMessage)
+	return p.reply(chatID, lang, "page_removed", nil)
+}
+
+// showAll lists the user's pages, "type:recipe", "lang:ru", "short" and "long" after the command narrow the list
+func (p *TgProcessor) showAll(chatID int, lang i18n.Lang, userName string, text string) error {
+	return p.showPages(userName, chatID, lang, strings.Fields(text)[1:], 0)
 }
 
-func (p *TgProcessor) showAll(chatID int, userName string) error {
-	pages, err := p.storage.PickAll(p.ctx, userName)
+// pageList is the part of the list sent in one go, Offset is the number of pages sent before
+type pageList struct {
+	Pages  []storage.Page
+	Offset int
+	Total  int
+}
+
+// showPages lists the pages matching the filter from the offset. Users with a page size get that many pages
+// at a time and a button for the next ones, its callback data is "more:offset:filter".
+func (p *TgProcessor) showPages(userName string, chatID int, lang i18n.Lang, args []string, offset int) error {
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	pages, err := p.storage.Select(p.ctx, userName, parseFilter(args))
 	if err != nil {
 		return fmt.Errorf("can't get pages: %w", err)
 	}
 
 	if len(pages) == 0 {
-		return p.tgClient.SendMessage(chatID, NoSavedPagesMessage)
+		return p.reply(chatID, lang, "no_saved_pages", nil)
 	}
-	var out string
-	for i, v := range pages {
-		out += fmt.Sprintf("\n%v. %v", i+1, v.URL)
+	if offset >= len(pages) {
+		return p.reply(chatID, lang, "no_more_pages", nil)
+	}
+	if settings.PageSize <= 0 {
+		return p.sendList(chatID, lang, settings, "page_list", pageList{Pages: pages, Total: len(pages)})
+	}
+
+	end := offset + settings.PageSize
+	if end > len(pages) {
+		end = len(pages)
+	}
+	text, err := templates.Render(lang, "page_list", pageList{Pages: pages[offset:end], Offset: offset, Total: len(pages)})
+	if err != nil {
+		return err
+	}
+
+	client := p.tgClient.WithLinkPreview(settings.LinkPreview)
+	data := fmt.Sprintf("%v%v%v%v%v", moreAction, callbackSeparator, end, callbackSeparator, strings.Join(args, " "))
+	if end == len(pages) || len(data) > maxCallbackData {
+		return client.SendHTML(chatID, text)
+	}
+
+	label, err := templates.RenderText(lang, "more_button", nil)
+	if err != nil {
+		return err
 	}
 
-	return p.tgClient.SendMessage(chatID, out)
+	return client.SendKeyboard(chatID, text, [][]telegram.InlineKeyboardButton{{{Text: label, CallbackData: data}}})
 }
 
-func (p *TgProcessor) showTags(userName string, chatID int) error {
+// morePages sends the next pages of the list, arg is "offset:filter"
+func (p *TgProcessor) morePages(meta Meta, lang i18n.Lang, arg string) error {
+	offset, args, _ := strings.Cut(arg, callbackSeparator)
+	n, err := strconv.Atoi(offset)
+	if err != nil || n < 0 {
+		n = 0
+	}
+
+	if err = p.tgClient.AnswerCallback(meta.ChatID, meta.CallbackID, ""); err != nil {
+		return err
+	}
+
+	return p.showPages(meta.UserName, meta.ChatID, lang, strings.Fields(args), n)
+}
+
+func (p *TgProcessor) showTags(userName string, chatID int, lang i18n.Lang) error {
 	tags, err := p.storage.SelectTags(p.ctx, userName)
 	if err != nil {
 		return err
 	}
 
 	if len(tags) == 0 {
-		p.tgClient.SendMessage(chatID, noTagsMessage)
+		p.reply(chatID, lang, "no_tags", nil)
 		return nil
 	}
 
-	return p.tgClient.SendTags(chatID, tags)
+	text, err := templates.Render(lang, "tags", nil)
+	if err != nil {
+		return err
+	}
+
+	// the tag is user text, the button refers to it by one of its pages
+	buttons := make([]telegram.InlineKeyboardButton, 0, len(tags))
+	for _, t := range tags {
+		buttons = append(buttons, telegram.InlineKeyboardButton{
+			Text:         strings.TrimSpace(t.Name),
+			CallbackData: showTagAction + callbackSeparator + strconv.Itoa(t.PageID),
+		})
+	}
+
+	return p.tgClient.SendTags(chatID, text, buttons)
+}
+
+// showTagByID lists the pages with the tag of the page the button refers to
+func (p *TgProcessor) showTagByID(userName string, chatID int, lang i18n.Lang, id string) error {
+	page, err := p.pageByID(userName, id)
+	var e *storage.NoResultError
+	if errors.As(err, &e) || err == nil && page.Tags == "" {
+		return p.reply(chatID, lang, "tags_changed", nil)
+	} else if err != nil {
+		return err
+	}
+
+	return p.showAllByTag(userName, chatID, lang, showAllByTag+" "+page.Tags)
 }
 
-func (p *TgProcessor) showAllByTag(userName string, chatID int, text string) error {
+func (p *TgProcessor) showAllByTag(userName string, chatID int, lang i18n.Lang, text string) error {
 	tag := strings.Join(strings.Split(text, " ")[1:], " ")
 	urls, err := p.storage.SelectByTag(p.ctx, tag, userName)
 	if err != nil {
 		return err
 	}
 	if len(urls) == 0 {
-		p.tgClient.SendMessage(chatID, noURLsForTagMessage)
+		p.reply(chatID, lang, "no_urls_for_tag", nil)
 		return nil
 	}
 
-	message := fmt.Sprintf("%v:\n%v", tag, strings.Join(urls, "\n"))
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	return p.sendList(chatID, lang, settings, "tag_list", struct {
+		Tag  string
+		URLs []string
+	}{tag, urls})
+}
+
+// retry sends failed pages back to the tag worker,
+// all of them or only the one passed after the command
+func (p *TgProcessor) retry(userName string, chatID int, lang i18n.Lang, text string) error {
+	pages, err := p.storage.SelectFailed(p.ctx, userName)
+	if err != nil {
+		return fmt.Errorf("can't get failed pages: %w", err)
+	}
+
+	splitArray := strings.Split(text, " ")
+	if len(splitArray) > 1 {
+		URL := splitArray[1]
+		filtered := pages[:0]
+		for _, v := range pages {
+			if v.URL == URL {
+				filtered = append(filtered, v)
+			}
+		}
+		pages = filtered
+	}
+
+	if len(pages) == 0 {
+		return p.reply(chatID, lang, "no_failed_pages", nil)
+	}
+
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+	for _, v := range pages {
+		v.Status = storage.StatusPending
+		v.StatusReason = ""
+		if settings.AutoTag {
+			p.tagWorker.AppendPage(v)
+		} else {
+			p.tagWorker.AppendUntagged(v)
+		}
+	}
+
+	return p.reply(chatID, lang, "retry_started", len(pages))
+}
+
+// retag sends pages to the classifier again. "all" takes the pages in the lists tagged by the classifier,
+// pages with manual tags are never retagged.
+func (p *TgProcessor) retag(userName string, chatID int, lang i18n.Lang, text string) error {
+	splitArray := strings.Split(text, " ")
+	if len(splitArray) < 2 {
+		return p.reply(chatID, lang, "retag_format", nil)
+	}
+
+	if splitArray[1] == retagAll {
+		settings, err := p.userSettings(userName)
+		if err != nil {
+			return err
+		}
+		if !settings.AutoTag {
+			return p.reply(chatID, lang, "autotag_off", nil)
+		}
+
+		pages, err := p.storage.PickAll(p.ctx, userName)
+		if err != nil {
+			return fmt.Errorf("can't get pages: %w", err)
+		}
+
+		toRetag := make([]storage.Page, 0, len(pages))
+		for _, v := range pages {
+			if !v.Archived && v.TagSource == storage.TagSourceML {
+				toRetag = append(toRetag, v)
+			}
+		}
+		go func() {
+			for _, v := range toRetag {
+				p.tagWorker.ReclassifyPage(v)
+			}
+		}()
+
+		return p.reply(chatID, lang, "retag_started", len(toRetag))
+	}
+
+	URL := splitArray[1]
+	if !isURL(URL) {
+		return p.reply(chatID, lang, "no_link", nil)
+	}
+
+	page, err := p.storage.Get(p.ctx, URL, userName)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "page_not_found", nil)
+	} else if err != nil {
+		return fmt.Errorf("can't get page: %w", err)
+	}
+
+	if page.TagSource == storage.TagSourceManual {
+		return p.reply(chatID, lang, "manual_tag", nil)
+	}
+	p.tagWorker.ReclassifyPage(*page)
+
+	return p.reply(chatID, lang, "retag_started", 1)
+}
+
+// setTag sets tag by hand, such tags are never changed by the classifier.
+// Without a tag the tags suggested by the page itself are offered as buttons.
+func (p *TgProcessor) setTag(userName string, chatID int, lang i18n.Lang, text string) error {
+	splitArray := strings.Split(text, " ")
+	if len(splitArray) < 2 || !isURL(splitArray[1]) {
+		return p.reply(chatID, lang, "tag_format", nil)
+	}
+
+	page, err := p.storage.Get(p.ctx, splitArray[1], userName)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "page_not_found", nil)
+	} else if err != nil {
+		return fmt.Errorf("can't get page: %w", err)
+	}
+
+	if len(splitArray) < 3 {
+		return p.suggestTags(chatID, lang, page)
+	}
+
+	return p.saveTag(chatID, lang, page, strings.Join(splitArray[2:], " "))
+}
+
+// suggestTags offers the page section and keywords, buttons refer to them by index
+func (p *TgProcessor) suggestTags(chatID int, lang i18n.Lang, page *storage.Page) error {
+	candidates := parser.TagCandidates(page.Metadata)
+	if len(candidates) == 0 {
+		return p.reply(chatID, lang, "tag_format", nil)
+	}
+
+	keyboard := make([][]telegram.InlineKeyboardButton, 0, len(candidates))
+	for i, c := range candidates {
+		keyboard = append(keyboard, []telegram.InlineKeyboardButton{{
+			Text:         c,
+			CallbackData: fmt.Sprintf("%v%v%v%v%v", tagAction, callbackSeparator, page.ID, callbackSeparator, i),
+		}})
+	}
+
+	text, err := templates.Render(lang, "tag_candidates", nil)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.SendKeyboard(chatID, text, keyboard)
+}
+
+// tagByID saves the candidate picked with a button, arg is "page id:candidate index"
+func (p *TgProcessor) tagByID(userName string, chatID int, lang i18n.Lang, arg string) error {
+	id, index, _ := strings.Cut(arg, callbackSeparator)
+	page, err := p.pageByID(userName, id)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "page_not_found", nil)
+	} else if err != nil {
+		return err
+	}
+
+	candidates := parser.TagCandidates(page.Metadata)
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(candidates) {
+		return p.reply(chatID, lang, "tag_format", nil)
+	}
+
+	return p.saveTag(chatID, lang, page, candidates[i])
+}
+
+func (p *TgProcessor) saveTag(chatID int, lang i18n.Lang, page *storage.Page, tag string) error {
+	page.Tags = tag
+	page.TagSource = storage.TagSourceManual
+	page.Classifier = ""
+	page.ModelVersion = ""
+	page.Status = storage.StatusTagged
+	page.StatusReason = ""
+	if err := p.storage.BatchUpdate(p.ctx, []storage.Page{*page}); err != nil {
+		return fmt.Errorf("can't update page: %w", err)
+	}
+
+	return p.reply(chatID, lang, "tag_set", tag)
+}
+
+// sendSnapshot sends the saved copy of the page as an HTML document
+func (p *TgProcessor) sendSnapshot(userName string, chatID int, lang i18n.Lang, text string) error {
+	splitArray := strings.Split(text, " ")
+	if len(splitArray) < 2 || !isURL(splitArray[1]) {
+		return p.reply(chatID, lang, "snapshot_format", nil)
+	}
+	URL := splitArray[1]
+
+	return p.snapshot(userName, chatID, lang, URL)
+}
+
+func (p *TgProcessor) snapshot(userName string, chatID int, lang i18n.Lang, URL string) error {
+	doc, err := p.archiver.Open(p.ctx, URL, userName)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "no_snapshot", nil)
+	} else if err != nil {
+		return fmt.Errorf("can't open snapshot: %w", err)
+	}
+
+	return p.tgClient.SendDocument(chatID, snapshotFileName(URL), doc, URL)
+}
+
+// showBroken lists links that are gone or parked with buttons to remove them or get their snapshots
+func (p *TgProcessor) showBroken(userName string, chatID int, lang i18n.Lang) error {
+	pages, err := p.storage.SelectBroken(p.ctx, userName)
+	if err != nil {
+		return fmt.Errorf("can't get broken pages: %w", err)
+	}
+	if len(pages) == 0 {
+		return p.reply(chatID, lang, "no_broken_links", nil)
+	}
+
+	removeLabel, err := templates.RenderText(lang, "remove_button", nil)
+	if err != nil {
+		return err
+	}
+	snapshotLabel, err := templates.RenderText(lang, "snapshot_button", nil)
+	if err != nil {
+		return err
+	}
+
+	keyboard := make([][]telegram.InlineKeyboardButton, 0, len(pages))
+	for i, v := range pages {
+		if i >= maxBrokenButtons {
+			break
+		}
+		id := strconv.Itoa(v.ID)
+		keyboard = append(keyboard, []telegram.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%v %v", removeLabel, i+1), CallbackData: removeAction + callbackSeparator + id},
+			{Text: fmt.Sprintf("%v %v", snapshotLabel, i+1), CallbackData: snapshotAction + callbackSeparator + id},
+		})
+	}
+
+	text, err := templates.Render(lang, "broken_list", pages)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.SendKeyboard(chatID, text, keyboard)
+}
+
+func (p *TgProcessor) removeByID(userName string, chatID int, lang i18n.Lang, id string) error {
+	page, err := p.pageByID(userName, id)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "page_not_found", nil)
+	} else if err != nil {
+		return err
+	}
+
+	if err = p.storage.Remove(p.ctx, page); err != nil {
+		return fmt.Errorf("can't remove page: %w", err)
+	}
+
+	return p.reply(chatID, lang, "page_removed_url", page.URL)
+}
+
+func (p *TgProcessor) snapshotByID(userName string, chatID int, lang i18n.Lang, id string) error {
+	page, err := p.pageByID(userName, id)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return p.reply(chatID, lang, "page_not_found", nil)
+	} else if err != nil {
+		return err
+	}
+
+	return p.snapshot(userName, chatID, lang, page.URL)
+}
+
+func (p *TgProcessor) pageByID(userName string, id string) (*storage.Page, error) {
+	ID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, storage.NewNoResultError()
+	}
+
+	page, err := p.storage.GetByID(p.ctx, ID, userName)
+	var e *storage.NoResultError
+	if err != nil && !errors.As(err, &e) {
+		return nil, fmt.Errorf("can't get page: %w", err)
+	}
+	return page, err
+}
+
+// setLanguage switches the language of replies, without a known language it offers them as buttons
+func (p *TgProcessor) setLanguage(userName string, chatID int, lang i18n.Lang, text string) error {
+	splitArray := strings.Fields(text)
+	if len(splitArray) < 2 {
+		return p.chooseLanguage(chatID, lang)
+	}
+	l, ok := i18n.Parse(splitArray[1])
+	if !ok {
+		return p.chooseLanguage(chatID, lang)
+	}
+
+	return p.saveLanguage(userName, chatID, l)
+}
+
+func (p *TgProcessor) chooseLanguage(chatID int, lang i18n.Lang) error {
+	buttons := make([]telegram.InlineKeyboardButton, 0, len(i18n.Langs))
+	for _, l := range i18n.Langs {
+		buttons = append(buttons, telegram.InlineKeyboardButton{
+			Text:         l.Name(),
+			CallbackData: langAction + callbackSeparator + string(l),
+		})
+	}
 
-	return p.tgClient.SendMessage(chatID, message)
+	text, err := templates.Render(lang, "language", lang.Name())
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.SendKeyboard(chatID, text, [][]telegram.InlineKeyboardButton{buttons})
+}
+
+// saveLanguage keeps the language in the user's settings and answers in it
+func (p *TgProcessor) saveLanguage(userName string, chatID int, lang i18n.Lang) error {
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	settings.Language = string(lang)
+	if err = p.saveSettings(settings, chatID, lang); err != nil {
+		return err
+	}
+
+	return p.reply(chatID, lang, "language_set", lang.Name())
+}
+
+func (p *TgProcessor) sendHelp(chatID int, lang i18n.Lang) error {
+	return p.reply(chatID, lang, "help", nil)
+}
 
+func (p *TgProcessor) start(chatID int, lang i18n.Lang) error {
+	return p.reply(chatID, lang, "hello", nil)
 }
 
-func (p *TgProcessor) sendHelp(chatID int) error {
-	return p.tgClient.SendMessage(chatID, helpMessage)
+// reply renders the named template and sends it
+func (p *TgProcessor) reply(chatID int, lang i18n.Lang, name string, data any) error {
+	text, err := templates.Render(lang, name, data)
+	if err != nil {
+		return err
+	}
+	return p.tgClient.SendHTML(chatID, text)
 }
 
-func (p *TgProcessor) start(chatID int) error {
-	return p.tgClient.SendMessage(chatID, helloMessage)
+// sendList renders the list and sends it as messages, or as a document when it is too long and the bot is set up so
+func (p *TgProcessor) sendList(chatID int, lang i18n.Lang, settings *storage.Settings, name string, data any) error {
+	text, err := templates.Render(lang, name, data)
+	if err != nil {
+		return err
+	}
+
+	client := p.tgClient.WithLinkPreview(settings.LinkPreview)
+	if p.listsAsFile {
+		caption, err := templates.Render(lang, "list_file_caption", nil)
+		if err != nil {
+			return err
+		}
+		return client.SendHTMLOrFile(chatID, text, listFileName, caption)
+	}
+	return client.SendHTML(chatID, text)
+}
+
+// snapshotFileName makes the file name from the page host and path: example.com_some_page.html
+func snapshotFileName(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "snapshot.html"
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(u.Host+u.Path, "/"))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return name + ".html"
 }
 
-func NewMessageSender(chatID int, tg *telegram.Client) func(string) error {
-	return func(msg string) error {
-		return tg.SendMessage(chatID, msg)
+// parseFilter reads "type:recipe", "lang:ru", "short" and "long" arguments of list commands
+func parseFilter(args []string) storage.Filter {
+	var f storage.Filter
+	for _, a := range args {
+		switch lower := strings.ToLower(a); {
+		case strings.HasPrefix(lower, typeFilter):
+			f.SchemaType = a[len(typeFilter):]
+		case strings.HasPrefix(lower, languageFilter):
+			f.Language = lower[len(languageFilter):]
+		case lower == shortFilter:
+			f.MaxReadingTime = shortReadingTime
+		case lower == longFilter:
+			f.MinReadingTime = longReadingTime
+		}
 	}
+	return f
 }
 
 func isURL(text string) bool {
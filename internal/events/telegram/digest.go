@@ -0,0 +1,220 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/i18n"
+	"url-saver-bot/internal/storage"
+)
+
+const (
+	digestTick = time.Minute
+	// digestGrace is how late a digest may still be sent, e.g. after a restart, later ones wait for the next slot
+	digestGrace = time.Hour
+	// snoozeFor keeps a snoozed page out of the digests
+	snoozeFor     = 7 * 24 * time.Hour
+	maxDigestSize = 30
+)
+
+// Digester sends opted-in users their unread links at the local hour they chose.
+// Each digest slot is claimed in the storage before sending, so restarts and several running bots
+// don't send it twice. A digest that fails after the claim isn't sent again.
+type Digester struct {
+	tgClient *telegram.Client
+	storage  storage.Storage
+	size     int
+	ctx      context.Context
+}
+
+// digestGroup is the digest pages with the same tags, N numbers them through the whole digest
+type digestGroup struct {
+	Tag   string
+	Pages []digestPage
+}
+
+type digestPage struct {
+	storage.Page
+	N int
+}
+
+func NewDigester(ctx context.Context, c *telegram.Client, s storage.Storage, size int) *Digester {
+	if size <= 0 || size > maxDigestSize {
+		size = maxDigestSize
+	}
+	return &Digester{
+		tgClient: c.WithPriority(telegram.PriorityBulk),
+		storage:  s,
+		size:     size,
+		ctx:      ctx,
+	}
+}
+
+func (d *Digester) Start() {
+	ticker := time.NewTicker(digestTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.SendDue(time.Now()); err != nil {
+				log.Printf("[ERR] digester: %v", err)
+			}
+		}
+	}
+}
+
+// SendDue sends the digests due at the given time that weren't sent yet
+func (d *Digester) SendDue(now time.Time) error {
+	users, err := d.storage.SelectDigests(d.ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range users {
+		settings := &users[i]
+		slot := settings.DigestSlot(now)
+		if slot.IsZero() || now.Sub(slot) > digestGrace {
+			continue
+		}
+
+		claimed, err := d.storage.ClaimDigest(d.ctx, settings.UserName, slot)
+		if err != nil {
+			// the slot stays unclaimed and is tried again on the next tick
+			log.Printf("[ERR] can't claim digest of %v: %v", settings.UserName, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err = d.send(settings, now); err != nil {
+			log.Printf("[ERR] can't send digest to %v: %v", settings.UserName, err)
+		}
+	}
+
+	return nil
+}
+
+// send sends the unread pages grouped by tags with buttons to read, archive or snooze each of them
+func (d *Digester) send(settings *storage.Settings, now time.Time) error {
+	pages, err := d.storage.SelectUnread(d.ctx, settings.UserName, now, d.size, settings.DigestRandom)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+
+	lang := scheduledLanguage(settings.Language, settings.ReplyLanguage)
+	groups := groupByTag(pages)
+	text, err := templates.Render(lang, "digest", groups)
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, 3)
+	for action, name := range map[string]string{
+		readAction:    "read_button",
+		archiveAction: "archive_button",
+		snoozeAction:  "snooze_button",
+	} {
+		if labels[action], err = templates.RenderText(lang, name, nil); err != nil {
+			return err
+		}
+	}
+
+	keyboard := make([][]telegram.InlineKeyboardButton, 0, len(pages))
+	for _, group := range groups {
+		for _, page := range group.Pages {
+			id := strconv.Itoa(page.ID)
+			row := make([]telegram.InlineKeyboardButton, 0, 3)
+			for _, action := range []string{readAction, archiveAction, snoozeAction} {
+				row = append(row, telegram.InlineKeyboardButton{
+					Text:         fmt.Sprintf("%v %v", labels[action], page.N),
+					CallbackData: action + callbackSeparator + id,
+				})
+			}
+			keyboard = append(keyboard, row)
+		}
+	}
+
+	return d.tgClient.WithLinkPreview(settings.LinkPreview).SendKeyboard(settings.ChatID, text, keyboard)
+}
+
+// groupByTag keeps the order of the pages, the groups go in the order of their first pages
+// and the pages are numbered in the order of the groups
+func groupByTag(pages []storage.Page) []digestGroup {
+	groups := make([]digestGroup, 0, len(pages))
+	index := make(map[string]int)
+	for _, page := range pages {
+		i, ok := index[page.Tags]
+		if !ok {
+			i = len(groups)
+			index[page.Tags] = i
+			groups = append(groups, digestGroup{Tag: page.Tags})
+		}
+		groups[i].Pages = append(groups[i].Pages, digestPage{Page: page})
+	}
+
+	n := 0
+	for i := range groups {
+		for j := range groups[i].Pages {
+			n++
+			groups[i].Pages[j].N = n
+		}
+	}
+	return groups
+}
+
+// markRead takes the page out of the lists the way /get does
+func (p *TgProcessor) markRead(meta Meta, lang i18n.Lang, id string) error {
+	settings, err := p.userSettings(meta.UserName)
+	if err != nil {
+		return err
+	}
+
+	return p.digestAction(meta, lang, id, "marked_read", func(page *storage.Page) error {
+		if settings.ArchiveOnGet {
+			return p.storage.Archive(p.ctx, page)
+		}
+		return p.storage.Remove(p.ctx, page)
+	})
+}
+
+func (p *TgProcessor) archiveByID(meta Meta, lang i18n.Lang, id string) error {
+	return p.digestAction(meta, lang, id, "page_archived", func(page *storage.Page) error {
+		return p.storage.Archive(p.ctx, page)
+	})
+}
+
+func (p *TgProcessor) snoozeByID(meta Meta, lang i18n.Lang, id string) error {
+	return p.digestAction(meta, lang, id, "page_snoozed", func(page *storage.Page) error {
+		return p.storage.Snooze(p.ctx, page, time.Now().Add(snoozeFor))
+	})
+}
+
+// digestAction applies the action of a digest button to its page and answers the press with the named notice
+func (p *TgProcessor) digestAction(meta Meta, lang i18n.Lang, id string, notice string, action func(page *storage.Page) error) error {
+	page, err := p.pageByID(meta.UserName, id)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		notice = "page_not_found"
+	} else if err != nil {
+		return err
+	} else if err = action(page); err != nil {
+		return fmt.Errorf("can't change page: %w", err)
+	}
+
+	text, err := templates.Render(lang, notice, nil)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.AnswerCallback(meta.ChatID, meta.CallbackID, text)
+}
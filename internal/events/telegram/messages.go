@@ -1,6 +1,16 @@
 package telegram
 
-const helpMessage = `
+import "url-saver-bot/internal/i18n"
+
+// templates are the bot replies in the HTML parse mode, values put into them are escaped.
+// Button texts, captions and answers to button presses are plain text, so they have no markup.
+var templates = i18n.Must(i18n.ParseHTML(map[i18n.Lang]string{
+	i18n.English: englishMessages,
+	i18n.Russian: russianMessages,
+}))
+
+const englishMessages = `
+{{- define "help" -}}
 Hello! I am url-saver, a bot that helps you save and tag your links. I use machine learning to automatically generate tags based on the content of the links.
 
 Here's how you can use me:
@@ -9,30 +19,144 @@ Here's how you can use me:
 3. In the future, you can use these tags to quickly search for and filter your saved links.
 
 Here are the available commands:
-- /get: Get the first saved link and remove it from the list.
+- /get: Get the first saved link and remove it from the list. Add <code>short</code> for a link under 10 minutes to read or <code>long</code> for one over 20 minutes.
 - /show_tags: Show all your tags.
-- /show_all: Show all saved links.
-- /remove: Remove a link from the list. Format: "/remove *link*"
+- /show_all: Show all saved links. Add <code>type:article</code>, <code>type:recipe</code>, <code>type:product</code> or <code>type:videoobject</code> to show links of one type, <code>lang:ru</code> or <code>lang:en</code> to show links in one language, <code>short</code> or <code>long</code> to show links by reading time.
+- /remove: Remove a link from the list. Format: <code>/remove link</code>
+- /retry: Try again to tag links that failed. Format: <code>/retry</code> or <code>/retry link</code>
+- /tag: Set your own tag for a link. Format: <code>/tag link tag</code>, or <code>/tag link</code> to pick one of the tags suggested by the page.
+- /retag: Tag links again with the current model. Format: <code>/retag link</code> or <code>/retag all</code>
+- /broken: Show links that no longer work, with buttons to remove them or get their saved copies.
+- /snapshot: Get the saved copy of a page, it stays available if the page disappears. Format: <code>/snapshot link</code>
+- /lang: Change the language of replies. Format: <code>/lang en</code> or <code>/lang ru</code>
+- /remind: Get a link back later. Format: <code>/remind link in 2h</code>, <code>/remind link tomorrow 9:00</code> or <code>/remind link saturday evening</code>. Reply <code>/remind when</code> to a message with a link to be reminded of it.
+- /settings: Change how many links are listed at once, whether /get removes or archives links, link previews, the digest and auto-tagging.
 
 If you have any questions or need help, simply type the command /help.
 
-Happy saving!`
+Happy saving!
+{{- end}}
+
+{{- define "hello"}}Hello!
+
+{{template "help"}}{{end}}
+
+{{- define "no_saved_pages"}}No saved pages.{{end}}
+{{- define "no_pages_of_length"}}No saved pages of this length.{{end}}
+{{- define "saved"}}URL saved.{{end}}
+{{- define "already_exists"}}This URL is already saved.{{end}}
+{{- define "unknown_command"}}Unknown command: <code>{{.}}</code>{{end}}
+{{- define "page_removed"}}Link successfully removed.{{end}}
+{{- define "page_removed_url"}}Link successfully removed: {{.}}{{end}}
+{{- define "no_link"}}No link in message.{{end}}
+{{- define "no_tags"}}Your links have no tags.{{end}}
+{{- define "tags"}}Here is all your tags:{{end}}
+{{- define "no_urls_for_tag"}}You have no URLs for this tag.{{end}}
+{{- define "tags_changed"}}Your tags have changed, send /show_tags again.{{end}}
+{{- define "no_failed_pages"}}You have no links that failed to be tagged.{{end}}
+{{- define "retry_started"}}Links sent for tagging again: <b>{{.}}</b>{{end}}
+{{- define "retag_started"}}Links sent for retagging: <b>{{.}}</b>{{end}}
+{{- define "autotag_off"}}Auto-tagging is off, turn it on in /settings to retag links.{{end}}
+{{- define "retag_format"}}Format: <code>/retag link</code> or <code>/retag all</code>{{end}}
+{{- define "tag_format"}}Format: <code>/tag link tag</code>{{end}}
+{{- define "tag_candidates"}}The page suggests these tags:{{end}}
+{{- define "tag_set"}}Tag <b>{{.}}</b> saved.{{end}}
+{{- define "page_not_found"}}This URL is not saved.{{end}}
+{{- define "manual_tag"}}This link has a tag set by you, it won't be changed.{{end}}
+{{- define "snapshot_format"}}Format: <code>/snapshot link</code>{{end}}
+{{- define "no_snapshot"}}There is no saved copy of this page. It isn't fetched yet, the site doesn't allow copies or your storage is full.{{end}}
+{{- define "no_broken_links"}}All your checked links work.{{end}}
+
+{{- /* length writes "7 min read, 1530 words", or "12 min" for videos */}}
+{{- define "length"}}{{minutes .ReadingTime}} {{if .WordCount}}min read, {{.WordCount}} {{plural .WordCount "word" "words"}}{{else}}min{{end}}{{end}}
+
+{{- /* picked_page is the page taken with /get, the url stays visible to be opened or shared */}}
+{{- define "picked_page"}}{{with .Title}}<b>{{.}}</b>
+{{end}}{{.URL}}{{if gt .ReadingTime 0}}
+<i>{{template "length" .}}</i>{{end}}{{end}}
+
+{{- /* page_list lists pages with their titles as links, tags, length and tagging problems */}}
+{{- define "page_list"}}{{range $i, $p := .Pages}}
+{{inc (add $.Offset $i)}}. <a href="{{$p.URL}}">{{or $p.Title $p.URL}}</a>
+{{- with $p.Tags}} <b>{{.}}</b>{{end}}
+{{- if gt $p.ReadingTime 0}} <i>({{template "length" $p}})</i>{{end}}
+{{- if eq $p.Status "failed"}} (not tagged: {{$p.StatusReason}}){{end}}
+{{- if eq $p.Status "not_fetched"}} (not fetched: {{$p.StatusReason}}){{end}}
+{{- end}}
+{{- if lt (len .Pages) .Total}}
+<i>{{inc .Offset}}–{{add .Offset (len .Pages)}} of {{.Total}}</i>{{end}}{{end}}
+
+{{- define "no_more_pages"}}There are no more links in the list.{{end}}
+{{- define "more_button"}}More{{end}}
+
+{{- define "tag_list"}}<b>{{.Tag}}</b>:{{range .URLs}}
+{{.}}{{end}}{{end}}
+
+{{- define "broken_list"}}These links no longer work:{{range $i, $p := .}}
+{{inc $i}}. {{$p.URL}} ({{$p.LinkStatus}}
+{{- if and (eq $p.LinkStatus "parked") $p.FinalURL}} at {{$p.FinalURL}}{{else if $p.HTTPStatus}}, status {{$p.HTTPStatus}}{{end}}
+{{- if $p.LastAlive.IsZero}}, never worked{{else}}, last worked <code>{{date $p.LastAlive}}</code>{{end}})
+{{- end}}{{end}}
+
+{{- define "language"}}Replies are in <b>{{.}}</b>. Pick another language:{{end}}
+{{- define "language_set"}}Replies are in <b>{{.}}</b> now.{{end}}
+
+{{- define "settings"}}<b>Settings</b>
+Press a button to change a setting. Other values are set with text: <code>/settings size 15</code>, <code>/settings hour 22</code> or <code>/settings tz Asia/Tbilisi</code>.{{end}}
+{{- define "settings_format"}}Format: <code>/settings size 15</code>, <code>/settings hour 22</code> or <code>/settings tz Asia/Tbilisi</code>. The page size is up to 100, 0 lists all links at once.{{end}}
+{{- define "setting_size"}}Links at once: {{if .PageSize}}{{.PageSize}}{{else}}all{{end}}{{end}}
+{{- define "setting_get"}}/get: {{if .ArchiveOnGet}}archives the link{{else}}removes the link{{end}}{{end}}
+{{- define "setting_preview"}}Link previews: {{if .LinkPreview}}on{{else}}off{{end}}{{end}}
+{{- define "setting_lang"}}Language: {{.Lang}}{{end}}
+{{- define "setting_digest"}}Digest: {{if eq .Digest "daily"}}daily{{else if eq .Digest "weekly"}}on Mondays{{else}}off{{end}}{{end}}
+{{- define "setting_order"}}Digest links: {{if .DigestRandom}}random{{else}}oldest{{end}}{{end}}
+{{- define "setting_hour"}}Digest time: {{printf "%02d:00" .DigestHour}}{{end}}
+{{- define "setting_tz"}}Time zone: {{or .Timezone "UTC"}}{{end}}
+{{- define "setting_autotag"}}Auto-tagging: {{if .AutoTag}}on{{else}}off{{end}}{{end}}
+
+{{- /* digest lists unread pages grouped by tags, the buttons under it refer to the numbers */}}
+{{- define "digest"}}<b>Links you haven't read yet</b>{{range .}}
+
+<b>{{or .Tag "Without tags"}}</b>{{range .Pages}}
+{{.N}}. <a href="{{.URL}}">{{or .Title .URL}}</a>{{if gt .ReadingTime 0}} <i>({{template "length" .}})</i>{{end}}
+{{- end}}{{end}}{{end}}
+
+{{- define "remind_format"}}Format: <code>/remind link when</code>, for example <code>/remind link in 2h</code>, <code>/remind link tomorrow 9:00</code> or <code>/remind link saturday evening</code>. Reply <code>/remind when</code> to a message with a link to be reminded of it.{{end}}
+{{- define "remind_past"}}This time has already passed.{{end}}
+{{- define "reminder_set"}}I'll remind you on <b>{{.Format "Mon, 2 Jan 15:04"}}</b> ({{.Format "MST"}}).{{end}}
+{{- define "reminder"}}You asked me to remind you of this link:
+{{with .Title}}<b>{{.}}</b>
+{{end}}{{.URL}}{{end}}
 
-const (
-	helloMessage          = "Hello!\n\n" + helpMessage
-	NoSavedPagesMessage   = "No saved pages."
-	SavedMessage          = "URL saved."
-	alreadyExistsMessage  = "This URL is already saved."
-	unknownCommandMessage = "Unknown command."
-	pageRemovedMessage    = "Link successfully removed."
-	noLinkMessage         = "No link in message."
-	noTagsMessage         = "Your links have no tags."
-	noURLsForTagMessage   = "You have no URLs for this tag."
-)
+{{- define "list_file_caption"}}The list is too long for a message, here it is as a file.{{end}}
+{{- define "remove_button"}}Remove{{end}}
+{{- define "snapshot_button"}}Snapshot{{end}}
+{{- define "read_button"}}Read{{end}}
+{{- define "archive_button"}}Archive{{end}}
+{{- define "snooze_button"}}Snooze{{end}}
+{{- define "marked_read"}}Marked as read.{{end}}
+{{- define "page_archived"}}Archived.{{end}}
+{{- define "page_snoozed"}}It won't be in digests for a week.{{end}}
+{{- define "remind_hour_button"}}In an hour{{end}}
+{{- define "remind_evening_button"}}Evening{{end}}
+{{- define "remind_tomorrow_button"}}Tomorrow{{end}}
+{{- define "remind_done_button"}}Done{{end}}
+{{- define "reminder_snoozed"}}I'll remind you again on {{.Format "Mon, 2 Jan 15:04"}}.{{end}}
+{{- define "reminder_done"}}Done.{{end}}
+{{- define "reminder_not_found"}}This reminder is already done.{{end}}
+`
 
 /*
-get - Get first saved link and remove it from list.
+get - Get first saved link and remove it from list. Format: "/get", "/get short" or "/get long"
 show_tags - Show all your tags.
-show_all - Show all saved links.
+show_all - Show all saved links. Format: "/show_all", "/show_all type:recipe", "/show_all lang:ru" or "/show_all short"
 remove - Remove link from list. Format: "/remove *link*"
+retry - Try again to tag failed links. Format: "/retry *link*"
+tag - Set your own tag. Format: "/tag *link* *tag*"
+retag - Tag links with the current model. Format: "/retag *link*" or "/retag all"
+snapshot - Get the saved copy of a page. Format: "/snapshot *link*"
+broken - Show links that no longer work.
+lang - Change the language of replies. Format: "/lang en" or "/lang ru"
+remind - Remind of a link later. Format: "/remind *link* in 2h" or "/remind *link* tomorrow 9:00"
+settings - Change your settings.
 */
@@ -0,0 +1,134 @@
+package telegram
+
+const russianMessages = `
+{{- define "help" -}}
+Привет! Я url-saver, бот, который помогает сохранять ссылки и расставлять им теги. Теги подбирает модель машинного обучения по содержимому страницы.
+
+Как мной пользоваться:
+1. Пришлите ссылку, которую хотите сохранить.
+2. Я загружу заголовок и текст страницы и подберу тег, который подходит к её содержимому.
+3. Потом по тегам можно быстро найти и отфильтровать сохранённые ссылки.
+
+Команды:
+- /get: Получить первую сохранённую ссылку и убрать её из списка. Добавьте <code>short</code>, чтобы получить ссылку, которую можно прочитать быстрее чем за 10 минут, или <code>long</code> — дольше чем за 20 минут.
+- /show_tags: Показать все ваши теги.
+- /show_all: Показать все сохранённые ссылки. Добавьте <code>type:article</code>, <code>type:recipe</code>, <code>type:product</code> или <code>type:videoobject</code>, чтобы показать ссылки одного типа, <code>lang:ru</code> или <code>lang:en</code> — ссылки на одном языке, <code>short</code> или <code>long</code> — ссылки по времени чтения.
+- /remove: Удалить ссылку из списка. Формат: <code>/remove ссылка</code>
+- /retry: Ещё раз расставить теги ссылкам, у которых не получилось. Формат: <code>/retry</code> или <code>/retry ссылка</code>
+- /tag: Поставить ссылке свой тег. Формат: <code>/tag ссылка тег</code> или <code>/tag ссылка</code>, чтобы выбрать один из тегов, которые предлагает сама страница.
+- /retag: Заново расставить теги текущей моделью. Формат: <code>/retag ссылка</code> или <code>/retag all</code>
+- /broken: Показать ссылки, которые больше не работают, с кнопками, чтобы удалить их или получить сохранённые копии.
+- /snapshot: Получить сохранённую копию страницы, она останется, даже если страница пропадёт. Формат: <code>/snapshot ссылка</code>
+- /lang: Сменить язык ответов. Формат: <code>/lang en</code> или <code>/lang ru</code>
+- /remind: Напомнить о ссылке позже. Формат: <code>/remind ссылка через 2 часа</code>, <code>/remind ссылка завтра в 9:00</code> или <code>/remind ссылка в субботу вечером</code>. Ответьте <code>/remind когда</code> на сообщение со ссылкой, чтобы напомнить о ней.
+- /settings: Настроить, сколько ссылок показывать за раз, удаляет ли /get ссылку или отправляет в архив, превью ссылок, дайджест и автоматические теги.
+
+Если нужна помощь, отправьте команду /help.
+
+Приятного чтения!
+{{- end}}
+
+{{- define "hello"}}Привет!
+
+{{template "help"}}{{end}}
+
+{{- define "no_saved_pages"}}Сохранённых ссылок нет.{{end}}
+{{- define "no_pages_of_length"}}Нет сохранённых ссылок такой длины.{{end}}
+{{- define "saved"}}Ссылка сохранена.{{end}}
+{{- define "already_exists"}}Эта ссылка уже сохранена.{{end}}
+{{- define "unknown_command"}}Неизвестная команда: <code>{{.}}</code>{{end}}
+{{- define "page_removed"}}Ссылка удалена.{{end}}
+{{- define "page_removed_url"}}Ссылка удалена: {{.}}{{end}}
+{{- define "no_link"}}В сообщении нет ссылки.{{end}}
+{{- define "no_tags"}}У ваших ссылок нет тегов.{{end}}
+{{- define "tags"}}Ваши теги:{{end}}
+{{- define "no_urls_for_tag"}}С этим тегом ссылок нет.{{end}}
+{{- define "tags_changed"}}Ваши теги изменились, отправьте /show_tags ещё раз.{{end}}
+{{- define "no_failed_pages"}}Нет ссылок, которым не удалось расставить теги.{{end}}
+{{- define "retry_started"}}Ещё раз отправлено на разметку: <b>{{.}}</b> {{plural . "ссылка" "ссылки" "ссылок"}}{{end}}
+{{- define "retag_started"}}Отправлено на повторную разметку: <b>{{.}}</b> {{plural . "ссылка" "ссылки" "ссылок"}}{{end}}
+{{- define "autotag_off"}}Автоматические теги выключены, включите их в /settings, чтобы обновить теги.{{end}}
+{{- define "retag_format"}}Формат: <code>/retag ссылка</code> или <code>/retag all</code>{{end}}
+{{- define "tag_format"}}Формат: <code>/tag ссылка тег</code>{{end}}
+{{- define "tag_candidates"}}Страница предлагает такие теги:{{end}}
+{{- define "tag_set"}}Тег <b>{{.}}</b> сохранён.{{end}}
+{{- define "page_not_found"}}Эта ссылка не сохранена.{{end}}
+{{- define "manual_tag"}}У этой ссылки ваш собственный тег, он не изменится.{{end}}
+{{- define "snapshot_format"}}Формат: <code>/snapshot ссылка</code>{{end}}
+{{- define "no_snapshot"}}Сохранённой копии этой страницы нет. Её ещё не загрузили, сайт запрещает копии или ваше хранилище заполнено.{{end}}
+{{- define "no_broken_links"}}Все проверенные ссылки работают.{{end}}
+
+{{- define "length"}}{{minutes .ReadingTime}} мин {{if .WordCount}}чтения, {{.WordCount}} {{plural .WordCount "слово" "слова" "слов"}}{{end}}{{end}}
+
+{{- define "picked_page"}}{{with .Title}}<b>{{.}}</b>
+{{end}}{{.URL}}{{if gt .ReadingTime 0}}
+<i>{{template "length" .}}</i>{{end}}{{end}}
+
+{{- define "page_list"}}{{range $i, $p := .Pages}}
+{{inc (add $.Offset $i)}}. <a href="{{$p.URL}}">{{or $p.Title $p.URL}}</a>
+{{- with $p.Tags}} <b>{{.}}</b>{{end}}
+{{- if gt $p.ReadingTime 0}} <i>({{template "length" $p}})</i>{{end}}
+{{- if eq $p.Status "failed"}} (без тегов: {{$p.StatusReason}}){{end}}
+{{- if eq $p.Status "not_fetched"}} (не загружена: {{$p.StatusReason}}){{end}}
+{{- end}}
+{{- if lt (len .Pages) .Total}}
+<i>{{inc .Offset}}–{{add .Offset (len .Pages)}} из {{.Total}}</i>{{end}}{{end}}
+
+{{- define "no_more_pages"}}Больше ссылок в списке нет.{{end}}
+{{- define "more_button"}}Ещё{{end}}
+
+{{- define "tag_list"}}<b>{{.Tag}}</b>:{{range .URLs}}
+{{.}}{{end}}{{end}}
+
+{{- define "broken_list"}}Эти ссылки больше не работают:{{range $i, $p := .}}
+{{inc $i}}. {{$p.URL}} ({{$p.LinkStatus}}
+{{- if and (eq $p.LinkStatus "parked") $p.FinalURL}} на {{$p.FinalURL}}{{else if $p.HTTPStatus}}, статус {{$p.HTTPStatus}}{{end}}
+{{- if $p.LastAlive.IsZero}}, ни разу не работала{{else}}, последний раз работала <code>{{date $p.LastAlive}}</code>{{end}})
+{{- end}}{{end}}
+
+{{- define "language"}}Язык ответов: <b>{{.}}</b>. Выберите другой:{{end}}
+{{- define "language_set"}}Теперь ответы на языке: <b>{{.}}</b>.{{end}}
+
+{{- define "settings"}}<b>Настройки</b>
+Нажмите на кнопку, чтобы изменить настройку. Другие значения задаются текстом: <code>/settings size 15</code>, <code>/settings hour 22</code> или <code>/settings tz Asia/Tbilisi</code>.{{end}}
+{{- define "settings_format"}}Формат: <code>/settings size 15</code>, <code>/settings hour 22</code> или <code>/settings tz Asia/Tbilisi</code>. Ссылок за раз — не больше 100, 0 показывает все ссылки сразу.{{end}}
+{{- define "setting_size"}}Ссылок за раз: {{if .PageSize}}{{.PageSize}}{{else}}все{{end}}{{end}}
+{{- define "setting_get"}}/get: {{if .ArchiveOnGet}}отправляет ссылку в архив{{else}}удаляет ссылку{{end}}{{end}}
+{{- define "setting_preview"}}Превью ссылок: {{if .LinkPreview}}вкл{{else}}выкл{{end}}{{end}}
+{{- define "setting_lang"}}Язык: {{.Lang}}{{end}}
+{{- define "setting_digest"}}Дайджест: {{if eq .Digest "daily"}}каждый день{{else if eq .Digest "weekly"}}по понедельникам{{else}}выкл{{end}}{{end}}
+{{- define "setting_order"}}Ссылки в дайджесте: {{if .DigestRandom}}случайные{{else}}самые старые{{end}}{{end}}
+{{- define "setting_hour"}}Время дайджеста: {{printf "%02d:00" .DigestHour}}{{end}}
+{{- define "setting_tz"}}Часовой пояс: {{or .Timezone "UTC"}}{{end}}
+{{- define "setting_autotag"}}Автоматические теги: {{if .AutoTag}}вкл{{else}}выкл{{end}}{{end}}
+
+{{- define "digest"}}<b>Ссылки, которые вы ещё не прочитали</b>{{range .}}
+
+<b>{{or .Tag "Без тегов"}}</b>{{range .Pages}}
+{{.N}}. <a href="{{.URL}}">{{or .Title .URL}}</a>{{if gt .ReadingTime 0}} <i>({{template "length" .}})</i>{{end}}
+{{- end}}{{end}}{{end}}
+
+{{- define "remind_format"}}Формат: <code>/remind ссылка когда</code>, например <code>/remind ссылка через 2 часа</code>, <code>/remind ссылка завтра в 9:00</code> или <code>/remind ссылка в субботу вечером</code>. Ответьте <code>/remind когда</code> на сообщение со ссылкой, чтобы напомнить о ней.{{end}}
+{{- define "remind_past"}}Это время уже прошло.{{end}}
+{{- define "reminder_set"}}Напомню <b>{{.Format "02.01 в 15:04"}}</b> ({{.Format "MST"}}).{{end}}
+{{- define "reminder"}}Вы просили напомнить об этой ссылке:
+{{with .Title}}<b>{{.}}</b>
+{{end}}{{.URL}}{{end}}
+
+{{- define "list_file_caption"}}Список слишком длинный для сообщения, вот он в файле.{{end}}
+{{- define "remove_button"}}Удалить{{end}}
+{{- define "snapshot_button"}}Копия{{end}}
+{{- define "read_button"}}Прочитано{{end}}
+{{- define "archive_button"}}В архив{{end}}
+{{- define "snooze_button"}}Отложить{{end}}
+{{- define "marked_read"}}Отмечено как прочитанное.{{end}}
+{{- define "page_archived"}}Отправлено в архив.{{end}}
+{{- define "page_snoozed"}}Ссылки не будет в дайджестах неделю.{{end}}
+{{- define "remind_hour_button"}}Через час{{end}}
+{{- define "remind_evening_button"}}Вечером{{end}}
+{{- define "remind_tomorrow_button"}}Завтра{{end}}
+{{- define "remind_done_button"}}Готово{{end}}
+{{- define "reminder_snoozed"}}Напомню ещё раз {{.Format "02.01 в 15:04"}}.{{end}}
+{{- define "reminder_done"}}Готово.{{end}}
+{{- define "reminder_not_found"}}Это напоминание уже выполнено.{{end}}
+`
@@ -0,0 +1,250 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/i18n"
+	"url-saver-bot/internal/storage"
+	"url-saver-bot/internal/when"
+)
+
+const (
+	reminderTick      = 30 * time.Second
+	reminderBatchSize = 100
+)
+
+// reminderSnoozes are the times of the snooze buttons under a reminder, they are read with when.Parse
+var reminderSnoozes = []struct {
+	when  string
+	label string
+}{
+	{"1h", "remind_hour_button"},
+	{"evening", "remind_evening_button"},
+	{"tomorrow", "remind_tomorrow_button"},
+}
+
+// Reminders sends the reminders that became due. They are claimed in the storage before sending,
+// so several running bots don't send a reminder twice and a failed one isn't sent again.
+type Reminders struct {
+	tgClient *telegram.Client
+	storage  storage.Storage
+	ctx      context.Context
+}
+
+// reminderView is what the reminder template shows, Title is empty for links that aren't saved
+type reminderView struct {
+	URL   string
+	Title string
+}
+
+func NewReminders(ctx context.Context, c *telegram.Client, s storage.Storage) *Reminders {
+	return &Reminders{
+		tgClient: c.WithPriority(telegram.PriorityBulk),
+		storage:  s,
+		ctx:      ctx,
+	}
+}
+
+func (r *Reminders) Start() {
+	ticker := time.NewTicker(reminderTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.SendDue(time.Now()); err != nil {
+				log.Printf("[ERR] reminders: %v", err)
+			}
+		}
+	}
+}
+
+// SendDue sends the reminders due by the given time
+func (r *Reminders) SendDue(now time.Time) error {
+	for {
+		reminders, err := r.storage.ClaimReminders(r.ctx, now, reminderBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range reminders {
+			if err = r.send(&reminders[i]); err != nil {
+				log.Printf("[ERR] can't send reminder to %v: %v", reminders[i].UserName, err)
+			}
+		}
+		if len(reminders) < reminderBatchSize {
+			return nil
+		}
+	}
+}
+
+// send sends the link with buttons to snooze the reminder or mark it done
+func (r *Reminders) send(rem *storage.Reminder) error {
+	settings, err := r.storage.GetSettings(r.ctx, rem.UserName)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		settings = storage.NewSettings(rem.UserName)
+	} else if err != nil {
+		return fmt.Errorf("can't get settings: %w", err)
+	}
+	lang := scheduledLanguage(settings.Language, rem.Language)
+
+	view := reminderView{URL: rem.URL}
+	if page, err := r.storage.Get(r.ctx, rem.URL, rem.UserName); err == nil {
+		view.Title = page.Title
+	}
+	text, err := templates.Render(lang, "reminder", view)
+	if err != nil {
+		return err
+	}
+
+	id := strconv.Itoa(rem.ID)
+	snoozes := make([]telegram.InlineKeyboardButton, 0, len(reminderSnoozes))
+	for _, s := range reminderSnoozes {
+		label, err := templates.RenderText(lang, s.label, nil)
+		if err != nil {
+			return err
+		}
+		snoozes = append(snoozes, telegram.InlineKeyboardButton{
+			Text:         label,
+			CallbackData: remindAction + callbackSeparator + id + callbackSeparator + s.when,
+		})
+	}
+	doneLabel, err := templates.RenderText(lang, "remind_done_button", nil)
+	if err != nil {
+		return err
+	}
+	keyboard := [][]telegram.InlineKeyboardButton{
+		snoozes,
+		{{Text: doneLabel, CallbackData: doneAction + callbackSeparator + id}},
+	}
+
+	return r.tgClient.WithLinkPreview(settings.LinkPreview).SendKeyboard(rem.ChatID, text, keyboard)
+}
+
+// remind saves a reminder of the link: "/remind link in 2h", "/remind link завтра в 9:00".
+// The time is in the user's time zone.
+func (p *TgProcessor) remind(userName string, chatID int, lang i18n.Lang, text string) error {
+	splitArray := strings.Fields(text)
+	if len(splitArray) < 3 || !isURL(splitArray[1]) {
+		return p.reply(chatID, lang, "remind_format", nil)
+	}
+
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	due, err := when.Parse(strings.Join(splitArray[2:], " "), time.Now().In(settings.Location()))
+	var pe *when.PastTimeError
+	if errors.As(err, &pe) {
+		return p.reply(chatID, lang, "remind_past", nil)
+	} else if err != nil {
+		return p.reply(chatID, lang, "remind_format", nil)
+	}
+
+	reminder := storage.Reminder{
+		UserName: userName,
+		ChatID:   chatID,
+		URL:      splitArray[1],
+		Due:      due,
+		Created:  time.Now(),
+		Language: string(lang),
+	}
+	if err = p.storage.SaveReminder(p.ctx, &reminder); err != nil {
+		return fmt.Errorf("can't save reminder: %w", err)
+	}
+
+	return p.reply(chatID, lang, "reminder_set", due)
+}
+
+// snoozeReminder makes the reminder due again, arg is "reminder id:when"
+func (p *TgProcessor) snoozeReminder(meta Meta, lang i18n.Lang, arg string) error {
+	settings, err := p.userSettings(meta.UserName)
+	if err != nil {
+		return err
+	}
+
+	id, w, _ := strings.Cut(arg, callbackSeparator)
+	reminder := storage.Reminder{UserName: meta.UserName}
+	if reminder.ID, err = strconv.Atoi(id); err != nil {
+		return fmt.Errorf("can't parse reminder id: %w", err)
+	}
+	if reminder.Due, err = when.Parse(w, time.Now().In(settings.Location())); err != nil {
+		return fmt.Errorf("can't parse snooze time: %w", err)
+	}
+
+	name := "reminder_snoozed"
+	err = p.storage.SnoozeReminder(p.ctx, &reminder)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		name = "reminder_not_found"
+	} else if err != nil {
+		return fmt.Errorf("can't snooze reminder: %w", err)
+	}
+
+	text, err := templates.Render(lang, name, reminder.Due)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.AnswerCallback(meta.ChatID, meta.CallbackID, text)
+}
+
+func (p *TgProcessor) doneReminder(meta Meta, lang i18n.Lang, id string) error {
+	reminder := storage.Reminder{UserName: meta.UserName}
+	var err error
+	if reminder.ID, err = strconv.Atoi(id); err != nil {
+		return fmt.Errorf("can't parse reminder id: %w", err)
+	}
+	name := "reminder_done"
+	err = p.storage.RemoveReminder(p.ctx, &reminder)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		name = "reminder_not_found"
+	} else if err != nil {
+		return fmt.Errorf("can't remove reminder: %w", err)
+	}
+
+	text, err := templates.Render(lang, name, nil)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.AnswerCallback(meta.ChatID, meta.CallbackID, text)
+}
+
+// withReplyURL puts the link of the replied message into "/remind when" sent as a reply
+func withReplyURL(text string, replyURL string) string {
+	splitArray := strings.Fields(text)
+	if replyURL == "" || len(splitArray) < 2 || splitArray[0] != remindCmd || isURL(splitArray[1]) {
+		return text
+	}
+	return strings.Join(append([]string{remindCmd, replyURL}, splitArray[1:]...), " ")
+}
+
+// messageURL returns the first link of the message, written in its text or hidden behind a text link
+func messageURL(m *telegram.IncomingMessage) string {
+	if m == nil {
+		return ""
+	}
+	for _, w := range strings.Fields(m.Text) {
+		if isURL(w) {
+			return w
+		}
+	}
+	for _, e := range m.Entities {
+		if e.Type == "text_link" && isURL(e.URL) {
+			return e.URL
+		}
+	}
+	return ""
+}
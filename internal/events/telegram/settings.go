@@ -0,0 +1,203 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"url-saver-bot/internal/clients/telegram"
+	"url-saver-bot/internal/i18n"
+	"url-saver-bot/internal/storage"
+)
+
+// settings are named in button callbacks "set:name" and in "/settings name value"
+const (
+	pageSizeSetting   = "size"
+	getSetting        = "get"
+	previewSetting    = "preview"
+	languageSetting   = "lang"
+	digestSetting     = "digest"
+	orderSetting      = "order"
+	digestHourSetting = "hour"
+	timezoneSetting   = "tz"
+	autoTagSetting    = "autotag"
+)
+
+// settingsMenu is the order of the /settings buttons
+var settingsMenu = []string{
+	pageSizeSetting, getSetting, previewSetting, languageSetting,
+	digestSetting, orderSetting, digestHourSetting, timezoneSetting, autoTagSetting,
+}
+
+// values the buttons go through, other page sizes, hours and time zones are set with text
+var (
+	pageSizes   = []int{0, 5, 10, 20}
+	digests     = []storage.Digest{storage.DigestOff, storage.DigestDaily, storage.DigestWeekly}
+	digestHours = []int{7, 8, 9, 12, 18, 20, 21}
+	timezones   = []string{"", "Europe/London", "Europe/Berlin", "Europe/Moscow", "Asia/Yekaterinburg",
+		"Asia/Novosibirsk", "Asia/Vladivostok", "America/New_York", "America/Los_Angeles"}
+)
+
+const maxPageSize = 100
+
+// settingsView is what the settings templates show, Lang is the name of the reply language
+type settingsView struct {
+	*storage.Settings
+	Lang string
+}
+
+// showSettings sends the settings as buttons, "/settings name value" sets the value first
+func (p *TgProcessor) showSettings(userName string, chatID int, lang i18n.Lang, text string) error {
+	settings, err := p.userSettings(userName)
+	if err != nil {
+		return err
+	}
+
+	splitArray := strings.Fields(text)
+	if len(splitArray) == 3 {
+		if !setSetting(settings, splitArray[1], splitArray[2]) {
+			return p.reply(chatID, lang, "settings_format", nil)
+		}
+		if err = p.saveSettings(settings, chatID, lang); err != nil {
+			return err
+		}
+	} else if len(splitArray) != 1 {
+		return p.reply(chatID, lang, "settings_format", nil)
+	}
+
+	text, keyboard, err := renderSettings(lang, settings)
+	if err != nil {
+		return err
+	}
+
+	return p.tgClient.SendKeyboard(chatID, text, keyboard)
+}
+
+// changeSetting moves the setting of the pressed button to its next value and edits the menu in place
+func (p *TgProcessor) changeSetting(meta Meta, lang i18n.Lang, name string) error {
+	settings, err := p.userSettings(meta.UserName)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case pageSizeSetting:
+		settings.PageSize = next(pageSizes, settings.PageSize)
+	case getSetting:
+		settings.ArchiveOnGet = !settings.ArchiveOnGet
+	case previewSetting:
+		settings.LinkPreview = !settings.LinkPreview
+	case languageSetting:
+		lang = next(i18n.Langs, lang)
+		settings.Language = string(lang)
+	case digestSetting:
+		settings.Digest = next(digests, settings.Digest)
+	case orderSetting:
+		settings.DigestRandom = !settings.DigestRandom
+	case digestHourSetting:
+		settings.DigestHour = next(digestHours, settings.DigestHour)
+	case timezoneSetting:
+		settings.Timezone = next(timezones, settings.Timezone)
+	case autoTagSetting:
+		settings.AutoTag = !settings.AutoTag
+	}
+	if err = p.saveSettings(settings, meta.ChatID, lang); err != nil {
+		return err
+	}
+
+	text, keyboard, err := renderSettings(lang, settings)
+	if err != nil {
+		return err
+	}
+	if err = p.tgClient.EditKeyboard(meta.ChatID, meta.MessageID, text, keyboard); err != nil {
+		return err
+	}
+
+	return p.tgClient.AnswerCallback(meta.ChatID, meta.CallbackID, "")
+}
+
+// setSetting sets the value written by the user: "size 15", "hour 22:00" or "tz Asia/Tbilisi"
+func setSetting(settings *storage.Settings, name string, value string) bool {
+	switch name {
+	case pageSizeSetting:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxPageSize {
+			return false
+		}
+		settings.PageSize = n
+	case digestHourSetting:
+		hour, _, _ := strings.Cut(value, ":")
+		n, err := strconv.Atoi(hour)
+		if err != nil || n < 0 || n > 23 {
+			return false
+		}
+		settings.DigestHour = n
+	case timezoneSetting:
+		if _, err := time.LoadLocation(value); err != nil || value == "Local" {
+			return false
+		}
+		settings.Timezone = value
+		if value == "UTC" {
+			settings.Timezone = ""
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func renderSettings(lang i18n.Lang, settings *storage.Settings) (string, [][]telegram.InlineKeyboardButton, error) {
+	view := settingsView{Settings: settings, Lang: lang.Name()}
+
+	text, err := templates.Render(lang, "settings", view)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keyboard := make([][]telegram.InlineKeyboardButton, 0, len(settingsMenu))
+	for _, name := range settingsMenu {
+		label, err := templates.RenderText(lang, "setting_"+name, view)
+		if err != nil {
+			return "", nil, err
+		}
+		keyboard = append(keyboard, []telegram.InlineKeyboardButton{{
+			Text:         label,
+			CallbackData: settingAction + callbackSeparator + name,
+		}})
+	}
+
+	return text, keyboard, nil
+}
+
+// userSettings returns the saved settings or the default ones of users who changed nothing
+func (p *TgProcessor) userSettings(userName string) (*storage.Settings, error) {
+	settings, err := p.storage.GetSettings(p.ctx, userName)
+	var e *storage.NoResultError
+	if errors.As(err, &e) {
+		return storage.NewSettings(userName), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't get settings: %w", err)
+	}
+	return settings, nil
+}
+
+// saveSettings saves the settings changed in the chat, the digest is sent there in the reply language
+func (p *TgProcessor) saveSettings(settings *storage.Settings, chatID int, lang i18n.Lang) error {
+	settings.ChatID = chatID
+	settings.ReplyLanguage = string(lang)
+	if err := p.storage.SaveSettings(p.ctx, settings); err != nil {
+		return fmt.Errorf("can't save settings: %w", err)
+	}
+	return nil
+}
+
+// next returns the value after v, or the first one when v isn't among the values
+func next[T comparable](values []T, v T) T {
+	for i, value := range values {
+		if value == v {
+			return values[(i+1)%len(values)]
+		}
+	}
+	return values[0]
+}
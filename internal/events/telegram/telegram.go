@@ -2,9 +2,15 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
+	"url-saver-bot/internal/archive"
 	"url-saver-bot/internal/clients/telegram"
 	"url-saver-bot/internal/events"
+	"url-saver-bot/internal/i18n"
 	"url-saver-bot/internal/ml/parser"
 	"url-saver-bot/internal/storage"
 )
@@ -14,27 +20,46 @@ type TgProcessor struct {
 	offset    int
 	storage   storage.Storage
 	tagWorker *parser.TagWorker
+	archiver  *archive.Archiver
 	ctx       context.Context
+	// listsAsFile sends long lists as a document
+	listsAsFile bool
 }
 
+// allowedUpdates are the update types the processor handles, others aren't fetched
+var allowedUpdates = []string{"message", "callback_query"}
+
 type Meta struct {
 	ChatID       int
 	UserID       int
 	UserName     string
+	LanguageCode string
+	// MessageID is the message with the pressed button
+	MessageID    int
+	CallbackID   string
 	CallbackData string
+	// ReplyURL is the first link of the message the user replied to
+	ReplyURL string
 }
 
-func New(ctx context.Context, c *telegram.Client, s storage.Storage, maxBufferSize int) *TgProcessor {
+func New(ctx context.Context, c *telegram.Client, s storage.Storage, w *parser.TagWorker, a *archive.Archiver,
+	listsAsFile bool) *TgProcessor {
 	return &TgProcessor{
-		tgClient:  c,
-		storage:   s,
-		tagWorker: parser.NewTagWorker(ctx, s, maxBufferSize),
-		ctx:       ctx,
+		tgClient:    c,
+		storage:     s,
+		tagWorker:   w,
+		archiver:    a,
+		ctx:         ctx,
+		listsAsFile: listsAsFile,
 	}
 }
 
 func (p *TgProcessor) Fetch(limit int) ([]events.Event, error) {
-	updates, err := p.tgClient.Updates(p.offset, limit)
+	updates, err := p.tgClient.Updates(p.offset, limit, allowedUpdates)
+	var rl *telegram.RateLimitError
+	if errors.As(err, &rl) {
+		time.Sleep(rl.RetryAfter)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can't get updates: %w", err)
 	}
@@ -54,14 +79,23 @@ func (p *TgProcessor) Fetch(limit int) ([]events.Event, error) {
 }
 
 func (p *TgProcessor) Process(e events.Event) error {
+	var err error
 	switch e.Type {
 	case events.Message:
-		return p.processMessage(e)
+		err = p.processMessage(e)
 	case events.Callback:
-		return p.processCallback(e)
+		err = p.processCallback(e)
 	default:
 		return NewUnknownTypeError()
 	}
+
+	// users who blocked the bot can't get the answer, it isn't the bot's failure
+	var fe *telegram.ForbiddenError
+	if errors.As(err, &fe) {
+		log.Printf("can't answer the user: %v", err)
+		return nil
+	}
+	return err
 }
 
 func (p *TgProcessor) processMessage(event events.Event) error {
@@ -70,7 +104,7 @@ func (p *TgProcessor) processMessage(event events.Event) error {
 		return fmt.Errorf("can't process message %w", err)
 	}
 
-	if err = p.doCmd(event.Text, meta.ChatID, meta.UserName); err != nil {
+	if err = p.doCmd(withReplyURL(event.Text, meta.ReplyURL), meta.ChatID, p.language(meta), meta.UserName); err != nil {
 		return fmt.Errorf("can't process message: %w", err)
 	}
 
@@ -82,13 +116,74 @@ func (p *TgProcessor) processCallback(event events.Event) error {
 	if err != nil {
 		return fmt.Errorf("can't process callback %w", err)
 	}
-	cmd := fmt.Sprintf("%v %v", showAllByTag, meta.CallbackData)
-	if err = p.doCmd(cmd, meta.ChatID, meta.UserName); err != nil {
-		return fmt.Errorf("can't process message: %w", err)
+
+	// buttons of /broken, /tag, /show_tags and digests carry "action:page id", buttons of /lang carry "lang:code",
+	// buttons of /settings carry "set:setting", reminder buttons carry "remind:reminder id:when" or "done:reminder id"
+	lang := p.language(meta)
+	action, arg, _ := strings.Cut(meta.CallbackData, callbackSeparator)
+	switch action {
+	case removeAction:
+		err = p.removeByID(meta.UserName, meta.ChatID, lang, arg)
+	case snapshotAction:
+		err = p.snapshotByID(meta.UserName, meta.ChatID, lang, arg)
+	case tagAction:
+		err = p.tagByID(meta.UserName, meta.ChatID, lang, arg)
+	case langAction:
+		l, _ := i18n.Parse(arg)
+		err = p.saveLanguage(meta.UserName, meta.ChatID, l)
+	case settingAction:
+		err = p.changeSetting(meta, lang, arg)
+	case moreAction:
+		err = p.morePages(meta, lang, arg)
+	case readAction:
+		err = p.markRead(meta, lang, arg)
+	case archiveAction:
+		err = p.archiveByID(meta, lang, arg)
+	case snoozeAction:
+		err = p.snoozeByID(meta, lang, arg)
+	case remindAction:
+		err = p.snoozeReminder(meta, lang, arg)
+	case doneAction:
+		err = p.doneReminder(meta, lang, arg)
+	case showTagAction:
+		err = p.showTagByID(meta.UserName, meta.ChatID, lang, arg)
+	default:
+		// buttons of /show_tags sent before the tags had ids carry the tag itself
+		err = p.reply(meta.ChatID, lang, "tags_changed", nil)
+	}
+	if err != nil {
+		return fmt.Errorf("can't process callback: %w", err)
 	}
 	return nil
 }
 
+// language is the one the user picked with /lang, or the language of their Telegram app
+func (p *TgProcessor) language(meta Meta) i18n.Lang {
+	settings, err := p.storage.GetSettings(p.ctx, meta.UserName)
+	var e *storage.NoResultError
+	if err != nil && !errors.As(err, &e) {
+		log.Printf("can't get settings of %v: %v", meta.UserName, err)
+	}
+	if err == nil && settings.Language != "" {
+		if l, ok := i18n.Parse(settings.Language); ok {
+			return l
+		}
+	}
+
+	l, _ := i18n.Parse(meta.LanguageCode)
+	return l
+}
+
+// scheduledLanguage is the language of digests and reminders: the one picked with /lang,
+// or the one the user was answered in when they set them up
+func scheduledLanguage(picked string, replied string) i18n.Lang {
+	if l, ok := i18n.Parse(picked); ok {
+		return l
+	}
+	l, _ := i18n.Parse(replied)
+	return l
+}
+
 func meta(e events.Event) (Meta, error) {
 	res, ok := e.Meta.(Meta)
 	if !ok {
@@ -109,15 +204,20 @@ func event(upd telegram.Update) events.Event {
 	switch updType {
 	case events.Message:
 		res.Meta = Meta{
-			ChatID:   upd.Message.Chat.ID,
-			UserID:   upd.Message.From.ID,
-			UserName: upd.Message.From.UserName,
+			ChatID:       upd.Message.Chat.ID,
+			UserID:       upd.Message.From.ID,
+			UserName:     upd.Message.From.UserName,
+			LanguageCode: upd.Message.From.LanguageCode,
+			ReplyURL:     messageURL(upd.Message.ReplyToMessage),
 		}
 	case events.Callback:
 		res.Meta = Meta{
 			ChatID:       upd.CallbackQuery.Message.Chat.ID,
 			UserID:       upd.CallbackQuery.From.ID,
 			UserName:     upd.CallbackQuery.From.UserName,
+			LanguageCode: upd.CallbackQuery.From.LanguageCode,
+			MessageID:    upd.CallbackQuery.Message.MessageID,
+			CallbackID:   upd.CallbackQuery.ID,
 			CallbackData: upd.CallbackQuery.Data,
 		}
 	}
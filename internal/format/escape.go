@@ -0,0 +1,51 @@
+// Package format renders bot messages for the Telegram parse modes and escapes text put into them.
+package format
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Telegram supports only these named entities, other characters go as they are
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+var (
+	markdownV2Escaper     = newBackslashEscaper("_*[]()~`>#+-=|{}.!\\")
+	markdownV2CodeEscaper = newBackslashEscaper("`\\")
+	markdownV2URLEscaper  = newBackslashEscaper(")\\")
+	tagRe                 = regexp.MustCompile(`<[^>]*>`)
+)
+
+// EscapeHTML makes the text safe inside HTML tags and attributes
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// EscapeMarkdownV2 makes the text safe outside of code and links
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Escaper.Replace(s)
+}
+
+// EscapeMarkdownV2Code makes the text safe inside `code` and ```pre``` blocks
+func EscapeMarkdownV2Code(s string) string {
+	return markdownV2CodeEscaper.Replace(s)
+}
+
+// EscapeMarkdownV2URL makes the URL safe inside (...) of a link
+func EscapeMarkdownV2URL(s string) string {
+	return markdownV2URLEscaper.Replace(s)
+}
+
+// StripHTML returns the plain text of a Telegram HTML message
+func StripHTML(s string) string {
+	return html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+}
+
+func newBackslashEscaper(chars string) *strings.Replacer {
+	pairs := make([]string, 0, 2*len(chars))
+	for _, c := range chars {
+		pairs = append(pairs, string(c), `\`+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}
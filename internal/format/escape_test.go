@@ -0,0 +1,42 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name     string
+		escape   func(string) string
+		reserved string
+	}{
+		{"text", EscapeMarkdownV2, "_*[]()~`>#+-=|{}.!\\"},
+		{"code", EscapeMarkdownV2Code, "`\\"},
+		{"url", EscapeMarkdownV2URL, ")\\"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// every printable ASCII character is escaped only when it is reserved in the context
+			for c := '!'; c <= '~'; c++ {
+				want := string(c)
+				if strings.ContainsRune(tt.reserved, c) {
+					want = `\` + want
+				}
+				if got := tt.escape(string(c)); got != want {
+					t.Errorf("got %q for %q, want %q", got, string(c), want)
+				}
+			}
+			if got := tt.escape("Привет, мир 👋"); got != "Привет, мир 👋" {
+				t.Errorf("got %q, want the text unchanged", got)
+			}
+		})
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	if got := EscapeHTML(`<b>"Tom" & 'Jerry'</b>`); got != "&lt;b&gt;&quot;Tom&quot; &amp; 'Jerry'&lt;/b&gt;" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+package format
+
+import (
+	"fmt"
+	htemplate "html/template"
+	"math"
+	"strings"
+	ttemplate "text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Mode is the Telegram parse mode the text is written in
+type Mode string
+
+const (
+	ModeHTML       Mode = "HTML"
+	ModeMarkdownV2 Mode = "MarkdownV2"
+)
+
+// MarkdownV2 is formatted text, it is put into MarkdownV2 templates without escaping
+type MarkdownV2 string
+
+// Templates are named message templates. Values put into them are always escaped:
+// HTML templates escape by context like html/template, MarkdownV2 templates escape
+// everything but the results of the formatting functions. The template text itself is
+// markup, so special characters in MarkdownV2 templates are escaped by hand: "{{inc $i}}\.".
+type Templates struct {
+	mode Mode
+	html *htemplate.Template
+	text *ttemplate.Template
+	// plain are the same templates without escaping, for button labels Telegram shows as is
+	plain *ttemplate.Template
+}
+
+// commonFuncs are available in templates of both modes
+var commonFuncs = map[string]any{
+	// inc numbers lists from one
+	"inc": func(i int) int { return i + 1 },
+	"add": func(a int, b int) int { return a + b },
+	// minutes rounds the duration up to whole minutes
+	"minutes": func(d time.Duration) int { return int(math.Ceil(d.Minutes())) },
+	"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+// markdownV2Funcs format MarkdownV2 text
+var markdownV2Funcs = map[string]any{
+	"bold":   func(v any) MarkdownV2 { return "*" + escapeMarkdownV2(v) + "*" },
+	"italic": func(v any) MarkdownV2 { return "_" + escapeMarkdownV2(v) + "_" },
+	"code":   func(v any) MarkdownV2 { return MarkdownV2("`" + EscapeMarkdownV2Code(fmt.Sprint(v)) + "`") },
+	"link": func(text any, url string) MarkdownV2 {
+		return "[" + escapeMarkdownV2(text) + "](" + MarkdownV2(EscapeMarkdownV2URL(url)) + ")"
+	},
+	markdownV2EscapeFunc: escapeMarkdownV2,
+}
+
+const markdownV2EscapeFunc = "escapeMarkdownV2"
+
+// ParseHTML parses templates for the HTML parse mode, they are defined with {{define "name"}}.
+// Funcs are added to the common functions.
+func ParseHTML(text string, funcs ...map[string]any) (*Templates, error) {
+	t := htemplate.New("").Funcs(commonFuncs)
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	t, err := t.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse templates: %w", err)
+	}
+	plain, err := parsePlain(text, funcs)
+	if err != nil {
+		return nil, err
+	}
+	return &Templates{mode: ModeHTML, html: t, plain: plain}, nil
+}
+
+// ParseMarkdownV2 parses templates for the MarkdownV2 parse mode, they are defined with {{define "name"}}.
+// Funcs are added to the common functions.
+func ParseMarkdownV2(text string, funcs ...map[string]any) (*Templates, error) {
+	t := ttemplate.New("").Funcs(commonFuncs)
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	t, err := t.Funcs(markdownV2Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse templates: %w", err)
+	}
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeActions(tt.Tree, tt.Tree.Root)
+		}
+	}
+	plain, err := parsePlain(text, append(funcs, markdownV2Funcs))
+	if err != nil {
+		return nil, err
+	}
+	return &Templates{mode: ModeMarkdownV2, text: t, plain: plain}, nil
+}
+
+func parsePlain(text string, funcs []map[string]any) (*ttemplate.Template, error) {
+	t := ttemplate.New("").Funcs(commonFuncs)
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	t, err := t.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse templates: %w", err)
+	}
+	return t, nil
+}
+
+// Must panics on the parse error, it is for templates known at compile time
+func Must(t *Templates, err error) *Templates {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Templates) Mode() Mode {
+	return t.mode
+}
+
+// Has reports whether the template is defined
+func (t *Templates) Has(name string) bool {
+	if t.html != nil {
+		return t.html.Lookup(name) != nil
+	}
+	return t.text.Lookup(name) != nil
+}
+
+// Render executes the named template
+func (t *Templates) Render(name string, data any) (string, error) {
+	var b strings.Builder
+	var err error
+	if t.html != nil {
+		err = t.html.ExecuteTemplate(&b, name, data)
+	} else {
+		err = t.text.ExecuteTemplate(&b, name, data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't render %v: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// RenderText executes the named template without escaping the values,
+// the result is plain text and not markup of the templates mode
+func (t *Templates) RenderText(name string, data any) (string, error) {
+	var b strings.Builder
+	if err := t.plain.ExecuteTemplate(&b, name, data); err != nil {
+		return "", fmt.Errorf("can't render %v: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+func escapeMarkdownV2(v any) MarkdownV2 {
+	if m, ok := v.(MarkdownV2); ok {
+		return m
+	}
+	return MarkdownV2(EscapeMarkdownV2(fmt.Sprint(v)))
+}
+
+// escapeActions pipes the output of every action into the escaping function,
+// the way html/template rewrites its templates
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeActions(tree, c)
+		}
+	case *parse.ActionNode:
+		// declarations like {{$x := .Title}} print nothing
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		id := parse.NewIdentifier(markdownV2EscapeFunc).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{id}})
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
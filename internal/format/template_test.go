@@ -0,0 +1,71 @@
+package format
+
+import "testing"
+
+const markdownV2Templates = `
+{{- define "action"}}*{{.}}*{{end}}
+{{- define "number"}}{{inc .}}\.{{end}}
+{{- define "printf"}}{{printf "%v." .}}{{end}}
+{{- define "bold"}}{{bold .}}{{end}}
+{{- define "italic"}}{{italic .}}{{end}}
+{{- define "code"}}{{code .}}{{end}}
+{{- define "link"}}{{link .Text .URL}}{{end}}
+{{- define "declaration"}}{{$t := .}}{{$t}}{{end}}
+{{- define "range"}}{{range .}}{{.}} {{else}}none{{end}}{{end}}
+{{- define "if"}}{{if .}}{{.}}{{else}}none\!{{end}}{{end}}
+{{- define "with"}}{{with .}}{{.}}{{end}}{{end}}
+{{- define "template"}}{{template "action" .}}{{end}}
+{{- define "formatted"}}{{.}}{{end}}`
+
+func TestParseMarkdownV2(t *testing.T) {
+	type link struct{ Text, URL string }
+	tests := []struct {
+		name string
+		data any
+		want string
+	}{
+		{"action", "a_b.c", `*a\_b\.c*`},
+		{"number", 0, `1\.`},
+		{"printf", 2, `2\.`},
+		{"bold", "x*y", `*x\*y*`},
+		{"italic", "x_y", `_x\_y_`},
+		{"code", "a`b_c\\", "`a\\`b_c\\\\`"},
+		{"link", link{"a.b", "https://example.com/(x)"}, `[a\.b](https://example.com/(x\))`},
+		{"declaration", "a.b", `a\.b`},
+		{"range", []string{"a.", "b!"}, `a\. b\! `},
+		{"range", []string{}, "none"},
+		{"if", "(x)", `\(x\)`},
+		{"if", "", `none\!`},
+		{"with", "#1", `\#1`},
+		{"template", "a-b", `*a\-b*`},
+		{"formatted", MarkdownV2("*kept*"), "*kept*"},
+	}
+
+	templates := Must(ParseMarkdownV2(markdownV2Templates))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templates.Render(tt.name, tt.data)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	html := Must(ParseHTML(`{{define "label"}}Time zone: {{.}}{{end}}`))
+	if got, _ := html.Render("label", "Etc/GMT+3 & <b>"); got != "Time zone: Etc/GMT&#43;3 &amp; &lt;b&gt;" {
+		t.Errorf("got %q from Render", got)
+	}
+	if got, _ := html.RenderText("label", "Etc/GMT+3 & <b>"); got != "Time zone: Etc/GMT+3 & <b>" {
+		t.Errorf("got %q from RenderText", got)
+	}
+
+	markdown := Must(ParseMarkdownV2(`{{define "label"}}Done: {{.}}{{end}}`))
+	if got, _ := markdown.RenderText("label", "1.5"); got != "Done: 1.5" {
+		t.Errorf("got %q from RenderText", got)
+	}
+}
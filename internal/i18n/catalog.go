@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"fmt"
+
+	"url-saver-bot/internal/format"
+)
+
+// Catalog keeps the message templates of every language. Templates are defined in the
+// default language, translations may leave some of them out and the default ones are used.
+type Catalog struct {
+	templates map[Lang]*format.Templates
+}
+
+// ParseHTML parses HTML templates of the languages, the default language must be among them
+func ParseHTML(texts map[Lang]string) (*Catalog, error) {
+	if _, ok := texts[Default]; !ok {
+		return nil, fmt.Errorf("no templates in %v", Default)
+	}
+
+	c := &Catalog{templates: make(map[Lang]*format.Templates, len(texts))}
+	for l, text := range texts {
+		t, err := format.ParseHTML(text, map[string]any{"plural": plural(l)})
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %v templates: %w", l, err)
+		}
+		c.templates[l] = t
+	}
+	return c, nil
+}
+
+// Must panics on the parse error, it is for catalogs known at compile time
+func Must(c *Catalog, err error) *Catalog {
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Render executes the named template of the language
+func (c *Catalog) Render(l Lang, name string, data any) (string, error) {
+	t, ok := c.templates[l]
+	if !ok || !t.Has(name) {
+		t = c.templates[Default]
+	}
+	return t.Render(name, data)
+}
+
+// RenderText executes the named template of the language without escaping, for button labels
+func (c *Catalog) RenderText(l Lang, name string, data any) (string, error) {
+	t, ok := c.templates[l]
+	if !ok || !t.Has(name) {
+		t = c.templates[Default]
+	}
+	return t.RenderText(name, data)
+}
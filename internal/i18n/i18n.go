@@ -0,0 +1,42 @@
+// Package i18n picks the language of the bot replies and keeps their translations.
+package i18n
+
+import "strings"
+
+// Lang is the ISO 639-1 code of a language the bot speaks
+type Lang string
+
+const (
+	English Lang = "en"
+	Russian Lang = "ru"
+	// Default is used when the user's language isn't known or the bot doesn't speak it
+	Default = English
+)
+
+// Langs are the languages the bot speaks, in the order they are offered to users
+var Langs = []Lang{English, Russian}
+
+// names are the languages in themselves, for buttons
+var names = map[Lang]string{
+	English: "English",
+	Russian: "Русский",
+}
+
+// Parse finds the language by the code, region subtags are ignored: "ru-RU" is Russian
+func Parse(code string) (Lang, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	for _, l := range Langs {
+		if Lang(code) == l {
+			return l, true
+		}
+	}
+	return Default, false
+}
+
+// Name is the language name in the language itself
+func (l Lang) Name() string {
+	return names[l]
+}
@@ -0,0 +1,39 @@
+package i18n
+
+// pluralForm returns the index of the word form for the count by CLDR cardinal rules.
+// English has one and other, Russian has one, few and many.
+func pluralForm(l Lang, n int) int {
+	if n < 0 {
+		n = -n
+	}
+	switch l {
+	case Russian:
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		default:
+			return 2
+		}
+	default:
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}
+}
+
+// plural picks the form for the count, templates call it as {{plural .Count "word" "words"}}
+func plural(l Lang) func(n int, forms ...string) string {
+	return func(n int, forms ...string) string {
+		if len(forms) == 0 {
+			return ""
+		}
+		i := pluralForm(l, n)
+		if i >= len(forms) {
+			i = len(forms) - 1
+		}
+		return forms[i]
+	}
+}
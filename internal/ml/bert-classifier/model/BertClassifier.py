@@ -1,3 +1,5 @@
+import hashlib
+import os
 import numpy as np
 from sklearn.metrics import f1_score
 import torch
@@ -27,6 +29,18 @@ class BertClassifier:
         self.model = torch.load(self.model_save_path)
         self.model.to(self.device)
 
+        self.name = model_path
+        self.languages = ('ru', 'en')
+        self.version = os.environ.get('MODEL_VERSION') or self.checkpoint_hash()
+
+    def checkpoint_hash(self):
+        # the checkpoint hash changes with every deployed model, so it works as a version
+        h = hashlib.sha256()
+        with open(self.model_save_path, 'rb') as f:
+            for chunk in iter(lambda: f.read(1 << 20), b''):
+                h.update(chunk)
+        return h.hexdigest()[:12]
+
     def preparation(self, X_train, y_train, X_valid, y_valid):
         # create datasets
         self.train_set = CustomDataset(X_train, y_train, self.tokenizer)
@@ -124,7 +138,10 @@ class BertClassifier:
 
         self.model = torch.load(self.model_save_path)
 
-    def predict(self, text):
+    def predict(self, text, language=''):
+        # the multilingual model reads any language, the code is only logged for now
+        if language and language not in self.languages:
+            print(f"predicting text in {language}, the model was trained on {', '.join(self.languages)}")
         encoding = self.tokenizer.encode_plus(
             text,
             add_special_tokens=True,
@@ -13,7 +13,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x17proto/bert_server.proto\x12\x04main\"\x1e\n\x0ePredictRequest\x12\x0c\n\x04text\x18\x01 \x01(\t\"%\n\x0fPredictResponse\x12\x12\n\nprediction\x18\x01 \x01(\t2H\n\x0e\x42\x65rtClassifier\x12\x36\n\x07Predict\x12\x14.main.PredictRequest\x1a\x15.main.PredictResponseB\x16Z\x14testBertClient/protob\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x17proto/bert_server.proto\x12\x04main\"0\n\x0ePredictRequest\x12\x0c\n\x04text\x18\x01 \x01(\t\x12\x10\n\x08language\x18\x02 \x01(\t\"P\n\x0fPredictResponse\x12\x12\n\nprediction\x18\x01 \x01(\t\x12\x12\n\nclassifier\x18\x02 \x01(\t\x12\x15\n\rmodel_version\x18\x03 \x01(\t\"\r\n\x0bInfoRequest\"9\n\x0cInfoResponse\x12\x12\n\nclassifier\x18\x01 \x01(\t\x12\x15\n\rmodel_version\x18\x02 \x01(\t2w\n\x0e\x42\x65rtClassifier\x12\x36\n\x07Predict\x12\x14.main.PredictRequest\x1a\x15.main.PredictResponse\x12-\n\x04Info\x12\x11.main.InfoRequest\x1a\x12.main.InfoResponseB\x16Z\x14testBertClient/protob\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -22,9 +22,13 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   DESCRIPTOR._options = None
   DESCRIPTOR._serialized_options = b'Z\024testBertClient/proto'
   _globals['_PREDICTREQUEST']._serialized_start=33
-  _globals['_PREDICTREQUEST']._serialized_end=63
-  _globals['_PREDICTRESPONSE']._serialized_start=65
-  _globals['_PREDICTRESPONSE']._serialized_end=102
-  _globals['_BERTCLASSIFIER']._serialized_start=104
-  _globals['_BERTCLASSIFIER']._serialized_end=176
+  _globals['_PREDICTREQUEST']._serialized_end=81
+  _globals['_PREDICTRESPONSE']._serialized_start=83
+  _globals['_PREDICTRESPONSE']._serialized_end=163
+  _globals['_INFOREQUEST']._serialized_start=165
+  _globals['_INFOREQUEST']._serialized_end=178
+  _globals['_INFORESPONSE']._serialized_start=180
+  _globals['_INFORESPONSE']._serialized_end=237
+  _globals['_BERTCLASSIFIER']._serialized_start=239
+  _globals['_BERTCLASSIFIER']._serialized_end=358
 # @@protoc_insertion_point(module_scope)
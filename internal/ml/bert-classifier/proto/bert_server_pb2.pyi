@@ -5,13 +5,31 @@ from typing import ClassVar as _ClassVar, Optional as _Optional
 DESCRIPTOR: _descriptor.FileDescriptor
 
 class PredictRequest(_message.Message):
-    __slots__ = ["text"]
+    __slots__ = ["text", "language"]
     TEXT_FIELD_NUMBER: _ClassVar[int]
+    LANGUAGE_FIELD_NUMBER: _ClassVar[int]
     text: str
-    def __init__(self, text: _Optional[str] = ...) -> None: ...
+    language: str
+    def __init__(self, text: _Optional[str] = ..., language: _Optional[str] = ...) -> None: ...
 
 class PredictResponse(_message.Message):
-    __slots__ = ["prediction"]
+    __slots__ = ["prediction", "classifier", "model_version"]
     PREDICTION_FIELD_NUMBER: _ClassVar[int]
+    CLASSIFIER_FIELD_NUMBER: _ClassVar[int]
+    MODEL_VERSION_FIELD_NUMBER: _ClassVar[int]
     prediction: str
-    def __init__(self, prediction: _Optional[str] = ...) -> None: ...
+    classifier: str
+    model_version: str
+    def __init__(self, prediction: _Optional[str] = ..., classifier: _Optional[str] = ..., model_version: _Optional[str] = ...) -> None: ...
+
+class InfoRequest(_message.Message):
+    __slots__ = []
+    def __init__(self) -> None: ...
+
+class InfoResponse(_message.Message):
+    __slots__ = ["classifier", "model_version"]
+    CLASSIFIER_FIELD_NUMBER: _ClassVar[int]
+    MODEL_VERSION_FIELD_NUMBER: _ClassVar[int]
+    classifier: str
+    model_version: str
+    def __init__(self, classifier: _Optional[str] = ..., model_version: _Optional[str] = ...) -> None: ...
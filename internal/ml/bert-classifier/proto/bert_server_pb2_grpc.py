@@ -19,6 +19,11 @@ class BertClassifierStub(object):
                 request_serializer=proto_dot_bert__server__pb2.PredictRequest.SerializeToString,
                 response_deserializer=proto_dot_bert__server__pb2.PredictResponse.FromString,
                 )
+        self.Info = channel.unary_unary(
+                '/main.BertClassifier/Info',
+                request_serializer=proto_dot_bert__server__pb2.InfoRequest.SerializeToString,
+                response_deserializer=proto_dot_bert__server__pb2.InfoResponse.FromString,
+                )
 
 
 class BertClassifierServicer(object):
@@ -30,6 +35,12 @@ class BertClassifierServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def Info(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_BertClassifierServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -38,6 +49,11 @@ def add_BertClassifierServicer_to_server(servicer, server):
                     request_deserializer=proto_dot_bert__server__pb2.PredictRequest.FromString,
                     response_serializer=proto_dot_bert__server__pb2.PredictResponse.SerializeToString,
             ),
+            'Info': grpc.unary_unary_rpc_method_handler(
+                    servicer.Info,
+                    request_deserializer=proto_dot_bert__server__pb2.InfoRequest.FromString,
+                    response_serializer=proto_dot_bert__server__pb2.InfoResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'main.BertClassifier', rpc_method_handlers)
@@ -64,3 +80,20 @@ class BertClassifier(object):
             proto_dot_bert__server__pb2.PredictResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Info(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/main.BertClassifier/Info',
+            proto_dot_bert__server__pb2.InfoRequest.SerializeToString,
+            proto_dot_bert__server__pb2.InfoResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
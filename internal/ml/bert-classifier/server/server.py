@@ -9,10 +9,17 @@ class BertServer(bert_server_pb2_grpc.BertClassifierServicer):
         self._clf = clf
 
     def Predict(self, request, context):
-        pred = self._clf.predict(request.text)
-        resp = bert_server_pb2.PredictResponse(prediction=pred)
+        pred = self._clf.predict(request.text, request.language)
+        resp = bert_server_pb2.PredictResponse(
+            prediction=pred,
+            classifier=self._clf.name,
+            model_version=self._clf.version,
+        )
         return resp
 
+    def Info(self, request, context):
+        return bert_server_pb2.InfoResponse(classifier=self._clf.name, model_version=self._clf.version)
+
 
 def serve(clf):
     server = grpc.server(futures.ThreadPoolExecutor(max_workers=10))
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"url-saver-bot/internal/storage"
+)
+
+const arxivAPI = "https://export.arxiv.org/api/query?id_list="
+
+// arxivFeed is the Atom response of the arXiv API
+type arxivFeed struct {
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Summary   string `xml:"summary"`
+		Published string `xml:"published"`
+		Authors   []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+	} `xml:"entry"`
+}
+
+// extractArxiv takes the paper title, authors and abstract from the arXiv API,
+// so PDF links get the abstract instead of the text layer
+func extractArxiv(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error) {
+	id := arxivID(u.Path)
+	if id == "" {
+		return Article{}, NewUnsupportedURLError()
+	}
+
+	body, err := apiGet(ctx, f, arxivAPI+neturl.QueryEscape(id), "application/atom+xml")
+	if err != nil {
+		return Article{}, err
+	}
+	var feed arxivFeed
+	if err = xml.Unmarshal(body, &feed); err != nil {
+		return Article{}, fmt.Errorf("can't decode arXiv response: %w", err)
+	}
+	// unknown ids come back as an entry describing the error
+	if len(feed.Entries) == 0 || strings.Contains(feed.Entries[0].ID, "/api/errors") {
+		return Article{}, NewNoDataError()
+	}
+	entry := feed.Entries[0]
+
+	authors := make([]string, 0, len(entry.Authors))
+	for _, a := range entry.Authors {
+		authors = append(authors, normalizeSpace(a.Name))
+	}
+	categories := make([]string, 0, len(entry.Categories))
+	for _, c := range entry.Categories {
+		categories = append(categories, c.Term)
+	}
+	var categoryLine string
+	if len(categories) > 0 {
+		categoryLine = "Categories: " + strings.Join(categories, ", ")
+	}
+
+	a := Article{
+		Title:  normalizeSpace(entry.Title),
+		Byline: strings.Join(authors, ", "),
+		Body:   joinParagraphs(normalizeSpace(entry.Summary), categoryLine),
+	}
+	a.Metadata = storage.Metadata{
+		Type:      schemaType("ScholarlyArticle"),
+		Headline:  a.Title,
+		Author:    a.Byline,
+		Published: parseDate(entry.Published),
+	}
+	return a, nil
+}
+
+// arxivID takes the paper id from abstract, PDF and HTML links.
+// Old style ids have a slash: /abs/hep-th/9901001.
+func arxivID(path string) string {
+	for _, prefix := range []string{"/abs/", "/pdf/", "/html/"} {
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimSuffix(strings.Trim(strings.TrimPrefix(path, prefix), "/"), ".pdf")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"url-saver-bot/internal/storage"
+)
+
+// content returns the page content from the cache shared by all users.
+// Fresh entries are used as is, expired ones are revalidated with a conditional request
+// and the page is fetched and classified only when it has changed or was never seen.
+func (w *TagWorker) content(client Classifier, key string, t task) (*storage.Content, error) {
+	cached, err := w.storage.GetContent(w.ctx, key)
+	var nr *storage.NoResultError
+	if errors.As(err, &nr) {
+		cached = nil
+	} else if err != nil {
+		w.errChan <- fmt.Errorf("can't get cached content: %w", err)
+		cached = nil
+	}
+
+	now := time.Now()
+	if cached != nil && now.Before(cached.Expires) {
+		if !needsPrediction(cached, t) {
+			return cached, nil
+		}
+		if cached.Body != "" {
+			return w.reclassifyCached(client, cached)
+		}
+	}
+
+	var validators Validators
+	// without the body a new prediction is impossible, so the page is fetched in full
+	if cached != nil && (cached.Body != "" || !needsPrediction(cached, t)) {
+		validators = Validators{ETag: cached.ETag, LastModified: cached.LastModified}
+	}
+
+	c, err := w.predict(client, t.page.URL, validators, t.untagged)
+	var nm *NotModifiedError
+	if errors.As(err, &nm) {
+		c = cached
+		if needsPrediction(cached, t) {
+			if err = w.classify(client, c, cachedArticle(cached)); err != nil {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		c.Fetched = now
+	}
+
+	c.URL = key
+	c.Expires = now.Add(w.cacheTTL)
+	if err = w.storage.SaveContent(w.ctx, c); err != nil {
+		w.errChan <- fmt.Errorf("can't cache content: %w", err)
+	}
+
+	return c, nil
+}
+
+func (w *TagWorker) reclassifyCached(client Classifier, c *storage.Content) (*storage.Content, error) {
+	if err := w.classify(client, c, cachedArticle(c)); err != nil {
+		return nil, err
+	}
+	if err := w.storage.SaveContent(w.ctx, c); err != nil {
+		w.errChan <- fmt.Errorf("can't cache content: %w", err)
+	}
+	return c, nil
+}
+
+func cachedArticle(c *storage.Content) Article {
+	return Article{
+		Title:       c.Title,
+		Byline:      c.Byline,
+		Body:        c.Body,
+		ContentType: c.ContentType,
+		Language:    c.Language,
+	}
+}
+
+// needsPrediction is true when the cached content was never classified or when the page
+// is reclassified and the cached prediction was made by the same model that tagged the page before
+func needsPrediction(c *storage.Content, t task) bool {
+	if t.untagged {
+		return false
+	}
+	// the content was cached for a user who tags pages by hand
+	if c.TagSource == "" {
+		return true
+	}
+	if !t.reclassify || c.TagSource != storage.TagSourceML {
+		return false
+	}
+	return c.Classifier == t.page.Classifier && c.ModelVersion == t.page.ModelVersion
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// tracking parameters that don't change the page content
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"yclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+	"ref_src": true,
+}
+
+// CanonicalURL brings different spellings of the same page to one form: lower case scheme
+// and host, no default port, fragment and tracking parameters, sorted query.
+// Unparsable URLs are returned as is.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = strings.TrimSuffix(host, ".")
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	query := u.Query()
+	for k := range query {
+		if trackingParams[strings.ToLower(k)] || strings.HasPrefix(strings.ToLower(k), "utm_") {
+			delete(query, k)
+		}
+	}
+	for _, v := range query {
+		sort.Strings(v)
+	}
+	// Encode sorts by key
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	htmlType  = "text/html"
+	xhtmlType = "application/xhtml+xml"
+	textType  = "text/plain"
+	pdfType   = "application/pdf"
+)
+
+// extractor gets the article from the response body of a certain content type
+type extractor func(content []byte, contentType string) (Article, error)
+
+// extractorFor routes content to the extractor of its media type.
+// Binary media are never parsed and reported with MediaError.
+func extractorFor(mediaType string) (extractor, error) {
+	switch {
+	case mediaType == htmlType || mediaType == xhtmlType:
+		return extractHTML, nil
+	case mediaType == textType:
+		return extractPlainText, nil
+	case mediaType == pdfType:
+		return extractPDF, nil
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return nil, NewMediaError(mediaType)
+	default:
+		return nil, NewUnsupportedTypeError(mediaType)
+	}
+}
+
+// mediaType takes the type from the header and sniffs the content
+// when the header is missing or too generic
+func mediaType(contentType string, content []byte) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mt == "" || mt == "application/octet-stream" || mt == "binary/octet-stream" {
+		mt, _, _ = mime.ParseMediaType(http.DetectContentType(content))
+	}
+	return strings.ToLower(mt)
+}
+
+// decode converts content to UTF-8 using the charset from the header, BOM or meta tags
+func decode(content []byte, contentType string) ([]byte, error) {
+	e, name, certain := charset.DetermineEncoding(content, contentType)
+	// windows-1252 is only a guess when nothing was declared
+	if !certain && name == "windows-1252" {
+		switch {
+		case utf8.Valid(content):
+			return content, nil
+		case looksLikeWindows1251(content):
+			e = charmap.Windows1251
+		}
+	}
+
+	if e == encoding.Nop {
+		return content, nil
+	}
+	return e.NewDecoder().Bytes(content)
+}
+
+// looksLikeWindows1251 checks that most of non-ASCII bytes are in the cyrillic letters range
+func looksLikeWindows1251(content []byte) bool {
+	var high, cyrillic int
+	for _, b := range content {
+		if b < 0x80 {
+			continue
+		}
+		high++
+		if b >= 0xC0 || b == 0xA8 || b == 0xB8 {
+			cyrillic++
+		}
+	}
+	return high > 0 && cyrillic*10 >= high*9
+}
+
+func extractHTML(content []byte, contentType string) (Article, error) {
+	content, err := decode(content, contentType)
+	if err != nil {
+		return Article{}, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return Article{}, err
+	}
+
+	a := extractArticle(doc)
+	a.HTML = content
+	return a, nil
+}
+
+func extractPlainText(content []byte, contentType string) (Article, error) {
+	content, err := decode(content, contentType)
+	if err != nil {
+		return Article{}, err
+	}
+
+	paragraphs := make([]string, 0, 32)
+	for _, p := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n\n") {
+		if p = normalizeSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	if len(paragraphs) == 0 {
+		return Article{}, nil
+	}
+
+	// the first line of a text file is usually its title
+	var a Article
+	first := strings.TrimSpace(strings.SplitN(strings.TrimSpace(string(content)), "\n", 2)[0])
+	if first != "" && utf8.RuneCountInString(first) <= 200 {
+		a.Title = normalizeSpace(first)
+	}
+	a.Body = strings.Join(paragraphs, "\n\n")
+
+	return a, nil
+}
@@ -1,5 +1,7 @@
 package parser
 
+import "fmt"
+
 type NoDataError struct {
 }
 
@@ -10,3 +12,116 @@ func (e *NoDataError) Error() string {
 func NewNoDataError() error {
 	return &NoDataError{}
 }
+
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.Code)
+}
+
+func NewStatusError(code int) error {
+	return &StatusError{Code: code}
+}
+
+// MediaError means the URL points to an image, video or audio file that has no text to classify
+type MediaError struct {
+	ContentType string
+}
+
+func (e *MediaError) Error() string {
+	return fmt.Sprintf("media content %v", e.ContentType)
+}
+
+func NewMediaError(contentType string) error {
+	return &MediaError{ContentType: contentType}
+}
+
+type UnsupportedTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported content type %v", e.ContentType)
+}
+
+func NewUnsupportedTypeError(contentType string) error {
+	return &UnsupportedTypeError{ContentType: contentType}
+}
+
+type RedirectError struct {
+	Limit int
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", e.Limit)
+}
+
+func NewRedirectError(limit int) error {
+	return &RedirectError{Limit: limit}
+}
+
+// BlockedError means the URL was not fetched because it points inside our network
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("fetch blocked: %v", e.Reason)
+}
+
+func NewBlockedError(reason string) error {
+	return &BlockedError{Reason: reason}
+}
+
+// HostBusyError means the host's request slots stayed taken for the whole fetch timeout
+type HostBusyError struct {
+	Host string
+}
+
+func (e *HostBusyError) Error() string {
+	return fmt.Sprintf("host %v is busy", e.Host)
+}
+
+func NewHostBusyError(host string) error {
+	return &HostBusyError{Host: host}
+}
+
+// DisallowedError means the site asked bots not to crawl the URL
+type DisallowedError struct {
+	Reason string
+}
+
+func (e *DisallowedError) Error() string {
+	return e.Reason
+}
+
+func NewDisallowedError(reason string) error {
+	return &DisallowedError{Reason: reason}
+}
+
+// NotModifiedError is returned for conditional requests when the cached page is still valid
+type NotModifiedError struct {
+}
+
+func (e *NotModifiedError) Error() string {
+	return "not modified"
+}
+
+func NewNotModifiedError() error {
+	return &NotModifiedError{}
+}
+
+// UnsupportedURLError means the site extractor has nothing for this kind of page,
+// e.g. a GitHub user profile instead of a repository
+type UnsupportedURLError struct {
+}
+
+func (e *UnsupportedURLError) Error() string {
+	return "unsupported URL"
+}
+
+func NewUnsupportedURLError() error {
+	return &UnsupportedURLError{}
+}
@@ -0,0 +1,297 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+const acceptHeader = "text/html,application/xhtml+xml,text/plain;q=0.9,application/pdf;q=0.8,*/*;q=0.5"
+
+// hostSweepInterval is how often limiters of hosts no longer requested are dropped
+const hostSweepInterval = time.Minute
+
+type FetcherConfig struct {
+	Timeout         time.Duration
+	MaxBodySize     int64
+	MaxRedirects    int
+	UserAgent       string
+	AcceptLanguage  string
+	HostConcurrency int
+	HostDelay       time.Duration
+	AllowNets       []string
+	DenyNets        []string
+	RespectRobots   bool
+	RobotsTTL       time.Duration
+}
+
+// Fetcher downloads pages with bounded time and size
+// and limits how often a single host is requested.
+type Fetcher struct {
+	client *http.Client
+	cfg    FetcherConfig
+	guard  *Guard
+	robots *robots
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+	swept time.Time
+}
+
+// hostLimiter allows HostConcurrency requests to the host at once,
+// each started no sooner than HostDelay after the previous one.
+// users counts requests waiting or running, it is guarded by Fetcher.mu.
+type hostLimiter struct {
+	sem   chan struct{}
+	users int
+	mu    sync.Mutex
+	next  time.Time
+}
+
+// Response is a fetched page. Body is cut at MaxBodySize.
+// NoIndex and NoArchive come from X-Robots-Tag and forbid keeping the page content.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FinalURL   string
+	Truncated  bool
+	NoIndex    bool
+	NoArchive  bool
+}
+
+func NewFetcher(cfg FetcherConfig) (*Fetcher, error) {
+	if cfg.HostConcurrency < 1 {
+		cfg.HostConcurrency = 1
+	}
+	guard, err := NewGuard(cfg.AllowNets, cfg.DenyNets)
+	if err != nil {
+		return nil, err
+	}
+
+	// no proxy: the guard has to see the address of the page itself
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   guard.control,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost:   cfg.HostConcurrency,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	f := &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+		},
+		cfg:   cfg,
+		guard: guard,
+		hosts: make(map[string]*hostLimiter),
+	}
+	f.client.CheckRedirect = f.checkRedirect
+	if cfg.RespectRobots {
+		f.robots = newRobots(f, cfg.UserAgent, cfg.RobotsTTL)
+	}
+
+	return f, nil
+}
+
+func (f *Fetcher) Get(ctx context.Context, url string) (*Response, error) {
+	return f.Do(ctx, http.MethodGet, url, nil)
+}
+
+// Do sends the request with the bot headers, extra headers are added on top of them.
+// URLs disallowed by robots.txt are not requested and DisallowedError is returned.
+func (f *Fetcher) Do(ctx context.Context, method string, url string, header http.Header) (*Response, error) {
+	if err := f.checkRobots(ctx, url); err != nil {
+		return nil, err
+	}
+
+	return f.do(ctx, method, url, header)
+}
+
+// checkRobots returns DisallowedError when robots.txt doesn't let us fetch the URL
+func (f *Fetcher) checkRobots(ctx context.Context, url string) error {
+	if f.robots == nil {
+		return nil
+	}
+
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("can't parse URL: %w", err)
+	}
+	if err = f.guard.checkURL(u); err != nil {
+		return err
+	}
+
+	allowed, err := f.robots.allowed(ctx, u)
+	if err != nil {
+		return fmt.Errorf("can't check robots.txt: %w", err)
+	}
+	if !allowed {
+		return NewDisallowedError("disallowed by robots.txt")
+	}
+	return nil
+}
+
+// checkRedirect applies the limits of the first request to every redirect hop
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= f.cfg.MaxRedirects {
+		return NewRedirectError(f.cfg.MaxRedirects)
+	}
+	if err := f.guard.checkURL(req.URL); err != nil {
+		return err
+	}
+	// robots.txt itself may be redirected anywhere
+	if loadingRobots(req.Context()) {
+		return nil
+	}
+	return f.checkRobots(req.Context(), req.URL.String())
+}
+
+func (f *Fetcher) do(ctx context.Context, method string, url string, header http.Header) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create request: %w", err)
+	}
+	if err = f.guard.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", acceptHeader)
+	if f.cfg.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", f.cfg.AcceptLanguage)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	// robots.txt skips the host limiter: it is requested at most once per ttl
+	// and a redirected request checks it while holding the host's slot
+	if !loadingRobots(ctx) {
+		release, err := f.wait(ctx, req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("can't read body: %w", err)
+	}
+
+	res := &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		FinalURL:   resp.Request.URL.String(),
+	}
+	res.NoIndex, res.NoArchive = robotsDirectives(resp.Header, agentToken(f.cfg.UserAgent))
+	// the beginning of a page is enough for the classifier
+	if int64(len(body)) > f.cfg.MaxBodySize {
+		res.Body = body[:f.cfg.MaxBodySize]
+		res.Truncated = true
+	}
+
+	return res, nil
+}
+
+// wait blocks until the host can be requested again and returns the function freeing the slot.
+// It gives up with HostBusyError after the fetch timeout.
+func (f *Fetcher) wait(ctx context.Context, host string) (func(), error) {
+	var deadline <-chan time.Time
+	var giveUp time.Time
+	if f.cfg.Timeout > 0 {
+		giveUp = time.Now().Add(f.cfg.Timeout)
+		timer := time.NewTimer(f.cfg.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	f.mu.Lock()
+	if now := time.Now(); now.Sub(f.swept) > hostSweepInterval {
+		f.sweep(now)
+		f.swept = now
+	}
+	l, ok := f.hosts[host]
+	if !ok {
+		l = &hostLimiter{sem: make(chan struct{}, f.cfg.HostConcurrency)}
+		f.hosts[host] = l
+	}
+	l.users++
+	f.mu.Unlock()
+
+	done := func() {
+		f.mu.Lock()
+		l.users--
+		f.mu.Unlock()
+	}
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	case <-deadline:
+		done()
+		return nil, NewHostBusyError(host)
+	}
+	release := func() {
+		<-l.sem
+		done()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	// a turn after the deadline isn't taken, so it doesn't delay the next requests
+	if !giveUp.IsZero() && start.After(giveUp) {
+		l.mu.Unlock()
+		release()
+		return nil, NewHostBusyError(host)
+	}
+	l.next = start.Add(f.cfg.HostDelay)
+	l.mu.Unlock()
+
+	if d := time.Until(start); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// sweep drops limiters of hosts nobody requests whose delay has passed, f.mu must be held
+func (f *Fetcher) sweep(now time.Time) {
+	for host, l := range f.hosts {
+		l.mu.Lock()
+		idle := l.users == 0 && now.After(l.next)
+		l.mu.Unlock()
+		if idle {
+			delete(f.hosts, host)
+		}
+	}
+}
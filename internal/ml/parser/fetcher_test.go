@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestFetcher(t *testing.T) *Fetcher {
+	t.Helper()
+	f, err := NewFetcher(FetcherConfig{
+		Timeout:         5 * time.Second,
+		MaxBodySize:     1 << 20,
+		MaxRedirects:    5,
+		UserAgent:       "url-saver-bot/1.0",
+		HostConcurrency: 1,
+		AllowNets:       []string{"127.0.0.0/8"},
+		RespectRobots:   true,
+		RobotsTTL:       time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFetcherChecksRobotsOnRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/page", http.StatusFound)
+	})
+	mux.HandleFunc("/private/page", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("disallowed page was requested")
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	_, err := newTestFetcher(t).Get(context.Background(), site.URL+"/public")
+	var de *DisallowedError
+	if !errors.As(err, &de) {
+		t.Fatalf("got error %v, want DisallowedError", err)
+	}
+}
+
+func TestFetcherFollowsRedirectedRobots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/rules.txt", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/rules.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: url-saver-bot\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	f := newTestFetcher(t)
+	resp, err := f.Get(context.Background(), site.URL+"/page")
+	if err != nil || string(resp.Body) != "ok" {
+		t.Fatalf("got %v, want the page", err)
+	}
+	_, err = f.Get(context.Background(), site.URL+"/private")
+	var de *DisallowedError
+	if !errors.As(err, &de) {
+		t.Fatalf("got error %v, want DisallowedError", err)
+	}
+}
+
+func TestFetcherRedirectsToAnotherPort(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, target.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer site.Close()
+
+	// both servers are on 127.0.0.1 and share the host limiter with its only slot
+	f := newTestFetcher(t)
+	resp, err := f.Get(context.Background(), site.URL+"/page")
+	if err != nil || string(resp.Body) != "ok" {
+		t.Fatalf("got %v, want the page", err)
+	}
+	_, err = f.Get(context.Background(), site.URL+"/private")
+	var de *DisallowedError
+	if !errors.As(err, &de) {
+		t.Fatalf("got error %v, want DisallowedError", err)
+	}
+}
+
+func TestFetcherGivesUpWaitingForBusyHost(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("busy host was requested")
+	}))
+	defer site.Close()
+
+	f, err := NewFetcher(FetcherConfig{
+		Timeout:      200 * time.Millisecond,
+		MaxBodySize:  1 << 10,
+		MaxRedirects: 5,
+		AllowNets:    []string{"127.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot, err := f.wait(context.Background(), "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slot()
+
+	_, err = f.Get(context.Background(), site.URL)
+	var hb *HostBusyError
+	if !errors.As(err, &hb) {
+		t.Fatalf("got error %v, want HostBusyError", err)
+	}
+}
+
+func TestFetcherHostLimiters(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer site.Close()
+
+	// no host concurrency configured still lets one request at a time through
+	f, err := NewFetcher(FetcherConfig{
+		Timeout:      time.Second,
+		MaxBodySize:  1 << 10,
+		MaxRedirects: 5,
+		AllowNets:    []string{"127.0.0.0/8"},
+		HostDelay:    time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Get(context.Background(), site.URL); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sweep(time.Now())
+	if len(f.hosts) != 1 {
+		t.Fatalf("got %d host limiters before the host delay passed, want 1", len(f.hosts))
+	}
+	f.sweep(time.Now().Add(2 * time.Minute))
+	if len(f.hosts) != 0 {
+		t.Fatalf("got %d host limiters of idle hosts, want 0", len(f.hosts))
+	}
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	githubAPI = "https://api.github.com/repos/"
+	// the beginning of a README tells enough about the project
+	maxReadmeLength = 20000
+)
+
+// githubReserved are first path segments that are GitHub pages, not owners
+var githubReserved = map[string]bool{
+	"about": true, "apps": true, "collections": true, "events": true, "explore": true, "features": true,
+	"login": true, "marketplace": true, "notifications": true, "orgs": true, "pricing": true, "search": true,
+	"settings": true, "sponsors": true, "topics": true, "trending": true, "users": true,
+}
+
+type githubRepo struct {
+	FullName        string   `json:"full_name"`
+	Description     string   `json:"description"`
+	Language        string   `json:"language"`
+	StargazersCount int      `json:"stargazers_count"`
+	Topics          []string `json:"topics"`
+	Owner           struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// extractGitHub describes a repository with its API data and README
+func extractGitHub(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || githubReserved[strings.ToLower(parts[0])] {
+		return Article{}, NewUnsupportedURLError()
+	}
+	repoPath := neturl.PathEscape(parts[0]) + "/" + neturl.PathEscape(strings.TrimSuffix(parts[1], ".git"))
+
+	var repo githubRepo
+	if err := getJSON(ctx, f, githubAPI+repoPath, &repo); err != nil {
+		return Article{}, err
+	}
+
+	facts := make([]string, 0, 3)
+	if repo.Language != "" {
+		facts = append(facts, "Language: "+repo.Language)
+	}
+	facts = append(facts, fmt.Sprintf("Stars: %d", repo.StargazersCount))
+	if len(repo.Topics) > 0 {
+		facts = append(facts, "Topics: "+strings.Join(repo.Topics, ", "))
+	}
+
+	// a repository without README is still described by its metadata
+	var readme string
+	if body, err := apiGet(ctx, f, githubAPI+repoPath+"/readme", "application/vnd.github.raw"); err == nil {
+		readme = truncateText(string(body), maxReadmeLength)
+	}
+
+	return Article{
+		Title:  repo.FullName,
+		Byline: repo.Owner.Login,
+		Body:   joinParagraphs(repo.Description, strings.Join(facts, "\n"), textParagraphs(readme)),
+	}, nil
+}
+
+// truncateText cuts the text to at most limit bytes without breaking a character
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
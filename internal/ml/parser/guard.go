@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// blockedNets are never fetched unless explicitly allowed:
+// loopback, private, link-local, multicast and other non-public ranges.
+// NAT64 and 6to4 addresses embed IPv4 ones and may reach private hosts.
+var blockedNets = mustParseNets(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// Guard keeps the fetcher from requesting addresses inside our network.
+// It checks the address after DNS resolution, so every connection
+// including redirect hops is verified.
+type Guard struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewGuard takes lists of CIDRs or single IPs. Allowed nets take priority
+// over the blocked by default ones, denied nets are blocked in addition to them.
+func NewGuard(allow []string, deny []string) (*Guard, error) {
+	allowNets, err := parseNets(allow)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse allow list: %w", err)
+	}
+	denyNets, err := parseNets(deny)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse deny list: %w", err)
+	}
+
+	return &Guard{
+		allow: allowNets,
+		deny:  denyNets,
+	}, nil
+}
+
+func (g *Guard) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return NewBlockedError(fmt.Sprintf("scheme %q is not allowed", u.Scheme))
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return g.checkIP(ip)
+	}
+	return nil
+}
+
+func (g *Guard) checkIP(ip net.IP) error {
+	if contains(g.deny, ip) {
+		return NewBlockedError(fmt.Sprintf("address %v is denied", ip))
+	}
+	if contains(g.allow, ip) {
+		return nil
+	}
+	if contains(blockedNets, ip) {
+		return NewBlockedError(fmt.Sprintf("address %v is not public", ip))
+	}
+	return nil
+}
+
+// control is called by the dialer with the resolved address before connecting
+func (g *Guard) control(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return NewBlockedError(fmt.Sprintf("bad address %q", address))
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return NewBlockedError(fmt.Sprintf("bad address %q", address))
+	}
+	return g.checkIP(ip)
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseNets(list ...string) []*net.IPNet {
+	nets, err := parseNets(list)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Language detection works on the script of the letters and, for Latin and Cyrillic
+// texts, on the most frequent words of each language. It is enough to tell apart
+// the languages we read, rarer ones come from <html lang>.
+
+const (
+	minDetectLetters = 40
+	minStopwordHits  = 3
+	// close languages share many words, the winner has to be ahead of the next one by this factor
+	minStopwordLead = 1.2
+)
+
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "this", "by", "be", "or", "from", "have", "you", "not", "an", "at", "which"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "ein", "eine", "zu", "auf", "für", "sich", "auch", "dem", "des", "von", "wird", "sie", "es", "ich", "wir"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "un", "du", "dans", "que", "pour", "qui", "pas", "sur", "au", "avec", "ce", "il", "sont", "nous", "vous", "aux"},
+	"es": {"el", "la", "los", "las", "y", "que", "de", "en", "es", "por", "una", "con", "para", "del", "se", "al", "lo", "como", "más", "pero", "sus", "fue"},
+	"it": {"il", "la", "che", "di", "e", "non", "per", "una", "sono", "della", "con", "gli", "del", "le", "anche", "più", "nel", "alla", "è", "questo"},
+	"pt": {"o", "a", "os", "as", "que", "de", "não", "uma", "um", "para", "com", "do", "da", "em", "é", "por", "mais", "dos", "das", "como", "mas", "foi"},
+	"ru": {"и", "в", "не", "на", "что", "с", "по", "это", "как", "к", "но", "из", "у", "за", "от", "так", "для", "же", "все", "он", "она", "они", "мы", "был", "или", "только"},
+	"uk": {"і", "в", "не", "на", "що", "з", "це", "як", "до", "але", "та", "від", "за", "для", "він", "вона", "вони", "ми", "був", "або", "тільки", "й", "є"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopwords))
+	for lang, words := range stopwords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}()
+
+// detectLanguage returns the ISO 639-1 code of the text language, or the declared one
+// when the text is too short or ambiguous
+func detectLanguage(text string, declared string) string {
+	if lang := detectText(text); lang != "" {
+		return lang
+	}
+	return normalizeLanguage(declared)
+}
+
+func detectText(text string) string {
+	var latin, cyrillic, han, kana, hangul, arabic, hebrew, greek, total int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		}
+	}
+	if total < minDetectLetters {
+		return ""
+	}
+
+	major := func(n int) bool { return n*2 > total }
+	switch {
+	case kana > 0 && major(kana+han):
+		return "ja"
+	case major(han):
+		return "zh"
+	case major(hangul):
+		return "ko"
+	case major(arabic):
+		return "ar"
+	case major(hebrew):
+		return "he"
+	case major(greek):
+		return "el"
+	case major(cyrillic):
+		return byStopwords(text, "ru", "uk")
+	case major(latin):
+		return byStopwords(text, "en", "de", "fr", "es", "it", "pt")
+	}
+	return ""
+}
+
+// byStopwords picks the candidate whose frequent words occur most often
+func byStopwords(text string, candidates ...string) string {
+	hits := make(map[string]int, len(candidates))
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for _, c := range candidates {
+			if stopwordSets[c][w] {
+				hits[c]++
+			}
+		}
+	}
+
+	best, second := "", ""
+	for _, c := range candidates {
+		switch {
+		case hits[c] > hits[best]:
+			best, second = c, best
+		case hits[c] > hits[second]:
+			second = c
+		}
+	}
+	if hits[best] < minStopwordHits || float64(hits[best]) < minStopwordLead*float64(hits[second]) {
+		return ""
+	}
+	return best
+}
+
+// normalizeLanguage takes the primary subtag: "en-US" -> "en"
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_,; "); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
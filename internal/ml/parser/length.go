@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// wordsPerMinute is the usual silent reading speed of an adult
+const wordsPerMinute = 230
+
+// countWords counts words of the text, numbers count as words too, dashes and other signs don't
+func countWords(text string) int {
+	var n int
+	for _, f := range strings.Fields(text) {
+		if strings.IndexFunc(f, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// articleLength returns the word count and the reading time of the article.
+// Videos are measured by their duration, words of their description aren't counted.
+func articleLength(a Article) (int, time.Duration) {
+	if a.Duration > 0 {
+		return 0, a.Duration
+	}
+	words := countWords(a.Body)
+	return words, time.Duration(words) * time.Minute / wordsPerMinute
+}
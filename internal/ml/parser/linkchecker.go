@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+	"url-saver-bot/internal/storage"
+)
+
+const (
+	linkCheckTick      = 10 * time.Minute
+	linkCheckBatchSize = 50
+	// deadAfter is how long a link may fail with network or server errors before it counts as dead
+	deadAfter = 7 * 24 * time.Hour
+)
+
+// parkingHosts serve placeholder pages of expired domains and domains for sale
+var parkingHosts = []string{
+	"sedoparking.com",
+	"sedo.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"above.com",
+	"hugedomains.com",
+	"dan.com",
+	"afternic.com",
+	"parklogic.com",
+	"domainmarket.com",
+}
+
+// LinkChecker requests saved links again on a schedule and records whether they are still alive.
+// Requests go through the fetcher, so its per-host limits and robots.txt rules apply.
+type LinkChecker struct {
+	fetcher  *Fetcher
+	storage  storage.Storage
+	interval time.Duration
+	ctx      context.Context
+}
+
+func NewLinkChecker(ctx context.Context, s storage.Storage, f *Fetcher, interval time.Duration) *LinkChecker {
+	return &LinkChecker{
+		fetcher:  f,
+		storage:  s,
+		interval: interval,
+		ctx:      ctx,
+	}
+}
+
+func (c *LinkChecker) Start() {
+	ticker := time.NewTicker(linkCheckTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.checkDue(); err != nil {
+				log.Printf("[ERR] link checker: %v", err)
+			}
+		}
+	}
+}
+
+// checkDue checks batches until no link is due, so every link is checked once per interval
+// however many links are saved. Checked links are due again only after the interval.
+func (c *LinkChecker) checkDue() error {
+	for c.ctx.Err() == nil {
+		n, err := c.check()
+		if err != nil || n < linkCheckBatchSize {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks a batch of due links and returns how many there were
+func (c *LinkChecker) check() (int, error) {
+	pages, err := c.storage.SelectUnchecked(c.ctx, time.Now().Add(-c.interval), linkCheckBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(pages) == 0 {
+		return 0, nil
+	}
+
+	// a link saved by several users is requested once
+	byURL := make(map[string][]int)
+	for i, p := range pages {
+		key := CanonicalURL(p.URL)
+		byURL[key] = append(byURL[key], i)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(byURL))
+	for _, idx := range byURL {
+		go func(idx []int) {
+			defer wg.Done()
+
+			res := c.request(pages[idx[0]].URL)
+			for _, i := range idx {
+				applyCheck(&pages[i], res)
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	return len(pages), c.storage.SaveChecks(c.ctx, pages)
+}
+
+// checkResult is the outcome of one link request
+type checkResult struct {
+	status     int
+	finalURL   string
+	err        error
+	checked    time.Time
+	linkStatus storage.LinkStatus
+}
+
+// request tries HEAD first and falls back to GET for servers that don't support it
+func (c *LinkChecker) request(url string) checkResult {
+	resp, err := c.fetcher.Do(c.ctx, http.MethodHead, url, nil)
+	if err == nil && headUnsupported(resp.StatusCode) {
+		resp, err = c.fetcher.Get(c.ctx, url)
+	}
+
+	res := checkResult{err: err, checked: time.Now()}
+	if err != nil {
+		res.linkStatus = linkStatusOf(err)
+		return res
+	}
+
+	res.status = resp.StatusCode
+	res.finalURL = resp.FinalURL
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		res.linkStatus = storage.LinkDead
+	case resp.StatusCode >= http.StatusInternalServerError:
+		res.err = NewStatusError(resp.StatusCode)
+		res.linkStatus = storage.LinkDead
+	case resp.StatusCode >= http.StatusBadRequest:
+		// 401, 403 and 429 usually mean we are not let in, not that the page is gone
+		res.linkStatus = storage.LinkUnknown
+	case isParked(resp.FinalURL):
+		res.linkStatus = storage.LinkParked
+	default:
+		res.linkStatus = storage.LinkAlive
+	}
+	return res
+}
+
+// applyCheck records the result on the page. Network and server errors mark the link dead
+// only when it hasn't been alive for deadAfter, a site or its DNS may be down for a while.
+func applyCheck(p *storage.Page, res checkResult) {
+	p.Checked = res.checked
+	p.HTTPStatus = res.status
+	if res.finalURL != "" {
+		p.FinalURL = res.finalURL
+	}
+
+	status := res.linkStatus
+	if status == storage.LinkDead && res.err != nil {
+		since := p.LastAlive
+		if since.IsZero() {
+			since = p.Created
+		}
+		if res.checked.Sub(since) < deadAfter {
+			status = storage.LinkUnknown
+		}
+	}
+
+	p.LinkStatus = status
+	if status == storage.LinkAlive {
+		p.LastAlive = res.checked
+	}
+}
+
+func linkStatusOf(err error) storage.LinkStatus {
+	var be *BlockedError
+	var de *DisallowedError
+	var re *RedirectError
+	var hb *HostBusyError
+	if errors.As(err, &be) || errors.As(err, &de) || errors.As(err, &re) || errors.As(err, &hb) {
+		return storage.LinkUnknown
+	}
+	if errors.Is(err, context.Canceled) {
+		return storage.LinkUnknown
+	}
+	return storage.LinkDead
+}
+
+func headUnsupported(code int) bool {
+	switch code {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusForbidden, http.StatusBadRequest:
+		return true
+	}
+	return false
+}
+
+func isParked(finalURL string) bool {
+	u, err := neturl.Parse(finalURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range parkingHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url-saver-bot/internal/storage"
+	"url-saver-bot/internal/storage/memory"
+)
+
+func TestLinkCheckerChecksEveryDueLink(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer site.Close()
+
+	ctx := context.Background()
+	s := memory.NewMemoryStorage()
+	// more links than one batch holds
+	n := 2*linkCheckBatchSize + 1
+	for i := 0; i < n; i++ {
+		err := s.Save(ctx, &storage.Page{URL: fmt.Sprintf("%v/%d", site.URL, i), UserName: "alice", Created: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := NewFetcher(FetcherConfig{
+		Timeout:         5 * time.Second,
+		MaxBodySize:     1 << 10,
+		MaxRedirects:    5,
+		HostConcurrency: 8,
+		AllowNets:       []string{"127.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = NewLinkChecker(ctx, s, f, 24*time.Hour).checkDue(); err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	pages, err := s.PickAll(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != n {
+		t.Fatalf("got %d pages, want %d", len(pages), n)
+	}
+	for _, p := range pages {
+		if p.LinkStatus != storage.LinkAlive {
+			t.Fatalf("got link %v %q, want all links checked", p.URL, p.LinkStatus)
+		}
+	}
+}
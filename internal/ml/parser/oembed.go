@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"url-saver-bot/internal/storage"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	youTubeOEmbed = "https://www.youtube.com/oembed?format=json&url="
+	twitterOEmbed = "https://publish.twitter.com/oembed?omit_script=true&dnt=true&url="
+)
+
+var (
+	// the full video description and length are only in the player data of the page
+	shortDescriptionRe = regexp.MustCompile(`"shortDescription":("(?:\\.|[^"\\])*")`)
+	lengthSecondsRe    = regexp.MustCompile(`"lengthSeconds":"(\d+)"`)
+	isoDurationRe      = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+	tweetPathRe        = regexp.MustCompile(`^/[^/]+/status(?:es)?/\d+`)
+)
+
+type oEmbed struct {
+	Title      string `json:"title"`
+	AuthorName string `json:"author_name"`
+	HTML       string `json:"html"`
+}
+
+// extractYouTube takes the title and channel from oEmbed
+// and the description and duration from the video page
+func extractYouTube(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error) {
+	id := youTubeVideoID(u)
+	if id == "" {
+		return Article{}, NewUnsupportedURLError()
+	}
+	watchURL := "https://www.youtube.com/watch?v=" + neturl.QueryEscape(id)
+
+	var o oEmbed
+	if err := getJSON(ctx, f, youTubeOEmbed+neturl.QueryEscape(watchURL), &o); err != nil {
+		return Article{}, err
+	}
+	a := Article{
+		Title:    o.Title,
+		Byline:   o.AuthorName,
+		Metadata: storage.Metadata{Type: "VideoObject", Headline: o.Title, Author: o.AuthorName},
+	}
+
+	resp, err := f.do(ctx, http.MethodGet, watchURL, nil)
+	if err != nil || resp.StatusCode >= 400 {
+		// title and channel are enough to go on
+		return a, nil
+	}
+
+	var description string
+	if m := shortDescriptionRe.FindSubmatch(resp.Body); m != nil {
+		_ = json.Unmarshal(m[1], &description)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err == nil {
+		if description == "" {
+			description, _ = doc.Find(`meta[property="og:description"]`).Attr("content")
+		}
+		if v, ok := doc.Find(`meta[itemprop="duration"]`).Attr("content"); ok {
+			a.Duration = parseISODuration(v)
+		}
+	}
+	if m := lengthSecondsRe.FindSubmatch(resp.Body); a.Duration == 0 && m != nil {
+		seconds, _ := strconv.Atoi(string(m[1]))
+		a.Duration = time.Duration(seconds) * time.Second
+	}
+
+	var duration string
+	if a.Duration > 0 {
+		duration = "Duration: " + formatDuration(a.Duration)
+	}
+	a.Body = joinParagraphs(textParagraphs(description), duration)
+
+	return a, nil
+}
+
+// youTubeVideoID supports watch, short, live and embed links
+func youTubeVideoID(u *neturl.URL) string {
+	if strings.EqualFold(u.Hostname(), "youtu.be") {
+		return strings.Trim(u.Path, "/")
+	}
+	if u.Path == "/watch" {
+		return u.Query().Get("v")
+	}
+	for _, prefix := range []string{"/shorts/", "/live/", "/embed/"} {
+		if strings.HasPrefix(u.Path, prefix) {
+			return strings.Trim(strings.TrimPrefix(u.Path, prefix), "/")
+		}
+	}
+	return ""
+}
+
+// extractTweet reads the post text from the oEmbed blockquote
+func extractTweet(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error) {
+	if !tweetPathRe.MatchString(u.Path) {
+		return Article{}, NewUnsupportedURLError()
+	}
+	tweetURL := "https://twitter.com" + u.Path
+
+	var o oEmbed
+	if err := getJSON(ctx, f, twitterOEmbed+neturl.QueryEscape(tweetURL), &o); err != nil {
+		return Article{}, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(o.HTML))
+	if err != nil {
+		return Article{}, fmt.Errorf("can't parse embedded post: %w", err)
+	}
+	paragraphs := make([]string, 0, 4)
+	doc.Find("blockquote p").Each(func(i int, s *goquery.Selection) {
+		paragraphs = append(paragraphs, normalizeSpace(s.Text()))
+	})
+
+	return Article{
+		Title:  "Post by " + o.AuthorName,
+		Byline: o.AuthorName,
+		Body:   joinParagraphs(paragraphs...),
+	}, nil
+}
+
+// parseISODuration parses durations like PT1H2M3S
+func parseISODuration(s string) time.Duration {
+	m := isoDurationRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		n, _ := strconv.Atoi(m[i+1])
+		d += time.Duration(n) * unit
+	}
+	return d
+}
+
+// formatDuration writes 1:02:03 or 2:03
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -1,52 +1,109 @@
 package parser
 
 import (
+	"context"
 	"fmt"
-	"github.com/PuerkitoBio/goquery"
 	"net/http"
 	"strings"
 	"unicode"
 )
 
+const minTextLength = 100
+
 type parser struct {
-	client *http.Client
+	fetcher *Fetcher
 }
 
-func NewParser() parser {
+func NewParser(f *Fetcher) parser {
 	return parser{
-		client: &http.Client{},
+		fetcher: f,
+	}
+}
+
+// Validators are taken from the response and sent back to check if the page changed
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+func (v Validators) header() http.Header {
+	h := http.Header{}
+	if v.ETag != "" {
+		h.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		h.Set("If-Modified-Since", v.LastModified)
 	}
+	return h
 }
 
-func (p parser) parse(url string) (string, error) {
-	response, err := p.client.Get(url)
-	if err != nil || response.StatusCode >= 400 {
-		return "", fmt.Errorf("error parsing URL %w", err)
+// parse fetches and extracts the page, pages of known sites go to their site extractors first.
+// With non-empty validators the request is conditional and NotModifiedError is returned
+// when the page hasn't changed.
+func (p parser) parse(ctx context.Context, url string, v Validators) (Article, Validators, error) {
+	// known sites are read from their APIs, such responses are not revalidated
+	article, ok, err := p.parseSite(ctx, url)
+	if err != nil {
+		return Article{}, Validators{}, fmt.Errorf("error parsing URL %w", err)
+	}
+	if ok {
+		article.Language = detectLanguage(article.Title+"\n"+article.Body, "")
+		return article, Validators{}, nil
+	}
+
+	response, err := p.fetcher.Do(ctx, http.MethodGet, url, v.header())
+	if err != nil {
+		return Article{}, Validators{}, fmt.Errorf("error parsing URL %w", err)
+	}
+	if response.StatusCode == http.StatusNotModified {
+		return Article{}, v, NewNotModifiedError()
+	}
+	if response.StatusCode >= 400 {
+		return Article{}, Validators{}, NewStatusError(response.StatusCode)
+	}
+
+	validators := Validators{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+	content := response.Body
+	contentType := response.Header.Get("Content-Type")
+	mt := mediaType(contentType, content)
+	extract, err := extractorFor(mt)
+	if err != nil {
+		return Article{ContentType: mt}, validators, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(response.Body)
+	article, err = extract(content, contentType)
 	if err != nil {
-		return "", fmt.Errorf("error parsing document %w", err)
+		return Article{ContentType: mt}, validators, fmt.Errorf("error parsing document %w", err)
+	}
+	article.ContentType = mt
+	article.URL = response.FinalURL
+	declared := article.Language
+	if declared == "" {
+		declared = response.Header.Get("Content-Language")
 	}
-	response.Body.Close()
-
-	texts := make([]string, 0, 100)
-	doc.Find("title").Each(func(i int, s *goquery.Selection) {
-		texts = append(texts, s.Text())
-	})
-	doc.Find("p").Each(func(i int, s *goquery.Selection) {
-		texts = append(texts, s.Text())
-	})
-
-	if len(texts) == 0 {
-		return "", NewNoDataError()
+	article.Language = detectLanguage(article.Title+"\n"+article.Body, declared)
+	article.NoIndex = article.NoIndex || response.NoIndex
+	article.NoArchive = article.NoArchive || response.NoArchive
+
+	if len(p.text(article)) < minTextLength {
+		return article, validators, NewNoDataError()
 	}
 
+	return article, validators, nil
+}
+
+// text prepares the article for the classifier: title and body in lower case
+// without symbols the model wasn't trained on
+func (p parser) text(a Article) string {
+	texts := append([]string{a.Title}, strings.Split(a.Body, "\n\n")...)
+
 	outTexts := make([]string, 0, len(texts))
 	for _, sentence := range texts {
 		cleaned := p.cleanString(sentence)
-
-		if cleaned == "" || strings.Contains(cleaned, "cookies") {
+		if cleaned == "" {
 			continue
 		}
 
@@ -61,20 +118,14 @@ func (p parser) parse(url string) (string, error) {
 				chars = append(chars, string(r))
 			}
 		}
-		outChars := p.cleanString(strings.Join(chars, ""))
-		if letterCount < 5 || strings.Contains(outChars, "security service to protect itself from online attacks") || strings.Contains(outChars, "attention required!") {
+		if letterCount < 5 {
 			continue
 		}
 
-		outTexts = append(outTexts, outChars)
-	}
-
-	out := strings.Join(outTexts, " ")
-	if len(out) < 100 {
-		return "", NewNoDataError()
+		outTexts = append(outTexts, p.cleanString(strings.Join(chars, "")))
 	}
 
-	return out, nil
+	return strings.Join(outTexts, " ")
 }
 
 func (p parser) cleanString(s string) string {
@@ -0,0 +1,364 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// PDF support is limited to the text layer: content streams are decompressed
+// and strings shown by the text operators are collected. Scanned documents
+// and text in composite (CID) fonts come out empty and end up as NoDataError.
+
+const maxPDFStreamSize = 16 << 20
+
+var (
+	streamRe   = regexp.MustCompile(`\bstream\r?\n`)
+	pdfTitleRe = regexp.MustCompile(`/Title\s*(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)`)
+)
+
+func extractPDF(content []byte, _ string) (Article, error) {
+	lines := make([]string, 0, 64)
+	for _, stream := range pdfStreams(content) {
+		if !bytes.Contains(stream, []byte("BT")) {
+			continue
+		}
+		lines = append(lines, pdfText(stream)...)
+	}
+
+	paragraphs := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = normalizeSpace(l); l != "" {
+			paragraphs = append(paragraphs, l)
+		}
+	}
+
+	a := Article{Body: strings.Join(paragraphs, "\n\n")}
+	if m := pdfTitleRe.FindSubmatch(content); m != nil {
+		a.Title = normalizeSpace(pdfString(m[1]))
+	}
+	if a.Title == "" && len(paragraphs) > 0 {
+		a.Title = paragraphs[0]
+	}
+
+	return a, nil
+}
+
+// pdfStreams returns decoded streams that may hold page content
+func pdfStreams(content []byte) [][]byte {
+	streams := make([][]byte, 0, 16)
+	for _, loc := range streamRe.FindAllIndex(content, -1) {
+		start := loc[1]
+		end := bytes.Index(content[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		data := content[start : start+end]
+
+		dictStart := bytes.LastIndex(content[:loc[0]], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := content[dictStart:loc[0]]
+		// images, fonts and other binary streams have no text
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/Length1")) ||
+			bytes.Contains(dict, []byte("/DCTDecode")) || bytes.Contains(dict, []byte("/ObjStm")) {
+			continue
+		}
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// broken streams are still useful up to the first error
+			decoded, _ := io.ReadAll(io.LimitReader(r, maxPDFStreamSize))
+			r.Close()
+			data = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+
+		streams = append(streams, data)
+	}
+	return streams
+}
+
+// pdfText runs text operators of the content stream and returns shown text line by line
+func pdfText(stream []byte) []string {
+	var (
+		lines    []string
+		line     strings.Builder
+		operands [][]byte
+	)
+	newLine := func() {
+		if line.Len() > 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+	}
+
+	t := pdfTokenizer{data: stream}
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+
+		if !isPDFOperator(tok) {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch string(tok) {
+		case "Tj", "'", "\"":
+			if len(operands) > 0 {
+				if string(tok) != "Tj" {
+					newLine()
+				}
+				line.WriteString(pdfString(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				line.WriteString(pdfArrayText(operands[len(operands)-1]))
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if y, err := strconv.ParseFloat(string(operands[len(operands)-1]), 64); err == nil && y != 0 {
+					newLine()
+				} else {
+					line.WriteByte(' ')
+				}
+			}
+		case "T*", "Tm", "ET":
+			newLine()
+		}
+		operands = operands[:0]
+	}
+	newLine()
+
+	return lines
+}
+
+func isPDFOperator(tok []byte) bool {
+	switch tok[0] {
+	case '(', '<', '[', '/':
+		return false
+	}
+	if _, err := strconv.ParseFloat(string(tok), 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// pdfArrayText joins strings of a TJ array, big negative offsets are gaps between words
+func pdfArrayText(arr []byte) string {
+	if len(arr) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+	t := pdfTokenizer{data: arr[1 : len(arr)-1]}
+	for {
+		tok, ok := t.next()
+		if !ok {
+			break
+		}
+		switch tok[0] {
+		case '(', '<':
+			sb.WriteString(pdfString(tok))
+		default:
+			if n, err := strconv.ParseFloat(string(tok), 64); err == nil && n < -200 {
+				sb.WriteByte(' ')
+			}
+		}
+	}
+	return sb.String()
+}
+
+// pdfString decodes literal and hex strings, UTF-16 strings are recognized by BOM
+func pdfString(tok []byte) string {
+	if len(tok) < 2 {
+		return ""
+	}
+
+	var raw []byte
+	if tok[0] == '<' {
+		hex := strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, string(tok[1:len(tok)-1]))
+		if len(hex)%2 == 1 {
+			hex += "0"
+		}
+		for i := 0; i+1 < len(hex); i += 2 {
+			b, err := strconv.ParseUint(hex[i:i+2], 16, 8)
+			if err != nil {
+				return ""
+			}
+			raw = append(raw, byte(b))
+		}
+	} else {
+		raw = unescapePDF(tok[1 : len(tok)-1])
+	}
+
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		u := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			u = append(u, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+
+	// without a font encoding table bytes are taken as Latin-1
+	out := make([]rune, 0, len(raw))
+	for _, b := range raw {
+		r := rune(b)
+		if unicode.IsPrint(r) || r == ' ' {
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
+
+func unescapePDF(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b', 'f':
+		case '\r', '\n':
+			// line continuation
+		default:
+			if c >= '0' && c <= '7' {
+				j := i
+				for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				n, _ := strconv.ParseUint(string(s[i:j]), 8, 8)
+				out = append(out, byte(n))
+				i = j - 1
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+type pdfTokenizer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token: string, hex string, array, dictionary, name, number or operator
+func (t *pdfTokenizer) next() ([]byte, bool) {
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		if c == '%' {
+			for t.pos < len(t.data) && t.data[t.pos] != '\n' && t.data[t.pos] != '\r' {
+				t.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			break
+		}
+		t.pos++
+	}
+	if t.pos >= len(t.data) {
+		return nil, false
+	}
+
+	start := t.pos
+	switch t.data[t.pos] {
+	case '(':
+		depth := 0
+	scan:
+		for ; t.pos < len(t.data); t.pos++ {
+			switch t.data[t.pos] {
+			case '\\':
+				t.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					break scan
+				}
+			}
+		}
+		t.pos++
+	case '<':
+		if t.pos+1 < len(t.data) && t.data[t.pos+1] == '<' {
+			t.skipNested('<', '>')
+		} else {
+			end := bytes.IndexByte(t.data[t.pos:], '>')
+			if end < 0 {
+				t.pos = len(t.data)
+			} else {
+				t.pos += end + 1
+			}
+		}
+	case '[':
+		t.skipNested('[', ']')
+	default:
+		t.pos++
+		for t.pos < len(t.data) && !isPDFSpace(t.data[t.pos]) && !isPDFDelimiter(t.data[t.pos]) {
+			t.pos++
+		}
+	}
+
+	if t.pos > len(t.data) {
+		t.pos = len(t.data)
+	}
+	return t.data[start:t.pos], true
+}
+
+// skipNested moves over arrays and dictionaries keeping strings inside intact
+func (t *pdfTokenizer) skipNested(open, close byte) {
+	depth := 0
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		if c == '(' {
+			sub := pdfTokenizer{data: t.data, pos: t.pos}
+			sub.next()
+			t.pos = sub.pos
+			continue
+		}
+		t.pos++
+		if c == open {
+			depth++
+		} else if c == close {
+			depth--
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return c == '(' || c == ')' || c == '<' || c == '>' || c == '[' || c == ']' || c == '/' || c == '{' || c == '}' || c == '%'
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPDF makes a PDF with one object per stream, the dictionary goes before each stream
+func buildPDF(info string, streams ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		dict := fmt.Sprintf("<< /Length %d >>", len(s))
+		if strings.HasPrefix(s, "<<") {
+			dict, s, _ = strings.Cut(s, "\n")
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n%v\nstream\n%v\nendstream\nendobj\n", i+1, dict, s)
+	}
+	if info != "" {
+		fmt.Fprintf(&b, "%d 0 obj\n<< %v >>\nendobj\n", len(streams)+1, info)
+	}
+	b.WriteString("trailer\n<< >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func deflate(s string) string {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(s))
+	w.Close()
+	return b.String()
+}
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   []byte
+		wantTitle string
+		wantBody  string
+	}{
+		{
+			name:      "each stream once",
+			content:   buildPDF("", "BT (Hello world) Tj ET", "BT (Second) Tj ET"),
+			wantTitle: "Hello world",
+			wantBody:  "Hello world\n\nSecond",
+		},
+		{
+			name:      "compressed stream",
+			content:   buildPDF("", "<< /Filter /FlateDecode >>\n"+deflate("BT (Packed text) Tj ET")),
+			wantTitle: "Packed text",
+			wantBody:  "Packed text",
+		},
+		{
+			name:      "title from the info dictionary",
+			content:   buildPDF("/Title (Annual report)", "BT (Intro) Tj ET"),
+			wantTitle: "Annual report",
+			wantBody:  "Intro",
+		},
+		{
+			name:      "UTF-16 title",
+			content:   buildPDF("/Title <FEFF04100431>", "BT (Text) Tj ET"),
+			wantTitle: "Аб",
+			wantBody:  "Text",
+		},
+		{
+			name:     "streams of images and unknown filters are skipped",
+			content:  buildPDF("", "<< /Subtype /Image >>\nBT (image) Tj ET", "<< /Filter /LZWDecode >>\nBT (lzw) Tj ET", "BT (kept) Tj ET"),
+			wantBody: "kept",
+		},
+		{
+			name:    "no text layer",
+			content: buildPDF("", "0 0 m 10 10 l S"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := extractPDF(tt.content, "application/pdf")
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if tt.wantTitle != "" && a.Title != tt.wantTitle {
+				t.Errorf("got title %q, want %q", a.Title, tt.wantTitle)
+			}
+			if a.Body != tt.wantBody {
+				t.Errorf("got body %q, want %q", a.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPDFText(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []string
+	}{
+		{"show and end", "BT /F1 12 Tf (One) Tj ET BT (Two) Tj ET", []string{"One", "Two"}},
+		{"move to the next line", "BT (First) Tj 0 -14 Td (Second) Tj ET", []string{"First", "Second"}},
+		{"move on the same line", "BT (left) Tj 40 0 Td (right) Tj ET", []string{"left right"}},
+		{"next line operators", "BT (a) Tj T* (b) Tj (c) ' ET", []string{"a", "b", "c"}},
+		{"TJ with kerning and gaps", "BT [(Hel) -20 (lo) -300 (world)] TJ ET", []string{"Hello world"}},
+		{"escapes", `BT (a\(b\) \101\\) Tj ET`, []string{"a(b) A\\"}},
+		{"nested parentheses", "BT (f(x) = y) Tj ET", []string{"f(x) = y"}},
+		{"hex string", "BT <48692> Tj ET", []string{"Hi "}},
+		{"comments", "BT % (hidden) Tj\n(shown) Tj ET", []string{"shown"}},
+		{"dictionary operand", "/Span << /ActualText (x) >> BDC BT (y) Tj ET EMC", []string{"y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pdfText([]byte(tt.stream)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
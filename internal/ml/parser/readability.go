@@ -0,0 +1,509 @@
+package parser
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"url-saver-bot/internal/storage"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Readability-like extraction of the main page content. Boilerplate is removed first,
+// then the block with the best text score is picked together with its good siblings.
+
+const (
+	minParagraphLength = 25
+	minArticleLength   = 250
+	maxLinkDensity     = 0.5
+)
+
+var (
+	unlikelyRe = regexp.MustCompile(`(?i)comment|disqus|footer|footnote|sidebar|sponsor|advert|\bads?\b|banner|cookie|consent|gdpr|popup|modal|share|social|related|subscribe|newsletter|\bnav|menu|breadcrumb|pagination|promo|widget|masthead|skip`)
+	likelyRe   = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeRe = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|sponsor|shoutbox|combx|related|tags|widget|hidden|author`)
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story|hentry`)
+	bylineRe   = regexp.MustCompile(`(?i)byline|author|writtenby|p-author`)
+	titleSepRe = regexp.MustCompile(`\s+[|\-–—:»·/]\s+`)
+)
+
+// bot protection pages answer with 200 sometimes, they are recognized by their markup and titles
+const challengeSelector = "#challenge-form, #challenge-running, #challenge-stage, #cf-wrapper, .cf-browser-verification, #px-captcha, #ddos-protection"
+
+var challengeTitles = map[string]bool{
+	"just a moment...":                 true,
+	"attention required! | cloudflare": true,
+	"ddos-guard":                       true,
+	"один момент…":                     true,
+}
+
+// tags that never contain the main text
+var junkTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Nav:      true,
+	atom.Aside:    true,
+	atom.Footer:   true,
+	atom.Header:   true,
+	atom.Svg:      true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Template: true,
+	atom.Dialog:   true,
+}
+
+var blockTags = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Blockquote: true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Li:         true,
+	atom.Main:       true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Td:         true,
+	atom.Th:         true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+	atom.Br:         true,
+}
+
+// Article is the main content of the page.
+// NoIndex and NoArchive mean the site doesn't allow us to keep its content.
+// HTML is the whole page decoded to UTF-8, it is kept for snapshots of HTML pages only.
+// Metadata is schema.org data the page publishes about itself.
+// Language is the ISO 639-1 code, detected from the text or declared by the page.
+type Article struct {
+	Title       string
+	Byline      string
+	Body        string
+	ContentType string
+	NoIndex     bool
+	NoArchive   bool
+	URL         string
+	HTML        []byte
+	Metadata    storage.Metadata
+	Language    string
+	// Duration is the length of videos, their reading time is the time to watch them
+	Duration time.Duration
+}
+
+func extractArticle(doc *goquery.Document) Article {
+	if isChallenge(doc) {
+		return Article{}
+	}
+
+	a := Article{
+		Title:  extractTitle(doc),
+		Byline: extractByline(doc),
+	}
+	a.NoIndex, a.NoArchive = metaRobots(doc)
+	a.Metadata = extractMetadata(doc)
+	a.Language = doc.Find("html").AttrOr("lang", "")
+	if a.Title == "" {
+		a.Title = a.Metadata.Headline
+	}
+	if a.Byline == "" {
+		a.Byline = a.Metadata.Author
+	}
+
+	removeBoilerplate(doc)
+
+	root := articleRoot(doc)
+	if root == nil {
+		return a
+	}
+
+	paragraphs := make([]string, 0, 32)
+	for _, n := range root {
+		paragraphs = append(paragraphs, blockTexts(n)...)
+	}
+	a.Body = strings.Join(dropTitle(paragraphs, a.Title), "\n\n")
+
+	return a
+}
+
+func extractTitle(doc *goquery.Document) string {
+	if t, ok := doc.Find(`meta[property="og:title"]`).Attr("content"); ok && strings.TrimSpace(t) != "" {
+		return normalizeSpace(t)
+	}
+
+	title := normalizeSpace(doc.Find("title").First().Text())
+	h1 := normalizeSpace(doc.Find("h1").First().Text())
+	if title == "" {
+		return h1
+	}
+
+	// cut the site name from "Article title | Site name"
+	if loc := titleSepRe.FindAllStringIndex(title, -1); len(loc) > 0 {
+		cut := strings.TrimSpace(title[:loc[len(loc)-1][0]])
+		if len(strings.Fields(cut)) >= 3 {
+			return cut
+		}
+	}
+	if h1 != "" && strings.Contains(title, h1) {
+		return h1
+	}
+
+	return title
+}
+
+func extractByline(doc *goquery.Document) string {
+	for _, sel := range []string{`meta[name="author"]`, `meta[property="article:author"]`} {
+		if v, ok := doc.Find(sel).Attr("content"); ok && strings.TrimSpace(v) != "" && !strings.HasPrefix(v, "http") {
+			return normalizeSpace(v)
+		}
+	}
+
+	var byline string
+	doc.Find(`[rel="author"], [itemprop="author"], [class], [id]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if s.Is(`[class], [id]`) && !s.Is(`[rel="author"], [itemprop="author"]`) {
+			class, _ := s.Attr("class")
+			id, _ := s.Attr("id")
+			if !bylineRe.MatchString(class + " " + id) {
+				return true
+			}
+		}
+
+		text := normalizeSpace(s.Text())
+		if text != "" && utf8.RuneCountInString(text) < 100 {
+			byline = text
+			return false
+		}
+		return true
+	})
+
+	return byline
+}
+
+// metaRobots reads <meta name="robots"> directives
+func metaRobots(doc *goquery.Document) (noIndex bool, noArchive bool) {
+	doc.Find(`meta[name="robots" i]`).Each(func(i int, s *goquery.Selection) {
+		content, _ := s.Attr("content")
+		for _, d := range strings.Split(content, ",") {
+			switch strings.ToLower(strings.TrimSpace(d)) {
+			case "noindex":
+				noIndex = true
+			case "noarchive":
+				noArchive = true
+			case "none":
+				noIndex, noArchive = true, true
+			}
+		}
+	})
+	return noIndex, noArchive
+}
+
+// removeBoilerplate drops navigation, comments, banners and other page chrome
+func removeBoilerplate(doc *goquery.Document) {
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		n := s.Get(0)
+		if n.Type != html.ElementNode {
+			return
+		}
+
+		if junkTags[n.DataAtom] || isHidden(s) {
+			s.Remove()
+			return
+		}
+
+		if role, _ := s.Attr("role"); role == "navigation" || role == "complementary" || role == "banner" ||
+			role == "contentinfo" || role == "dialog" || role == "alertdialog" || role == "alert" {
+			s.Remove()
+			return
+		}
+
+		// consent banners and popups are modal or pinned over the page
+		if modal, _ := s.Attr("aria-modal"); modal == "true" || isPinned(s) {
+			s.Remove()
+			return
+		}
+
+		if n.DataAtom == atom.Body || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+			return
+		}
+
+		match := className(s)
+		if match != "" && unlikelyRe.MatchString(match) && !likelyRe.MatchString(match) {
+			s.Remove()
+		}
+	})
+}
+
+// articleRoot returns the nodes holding the main text
+func articleRoot(doc *goquery.Document) []*html.Node {
+	for _, sel := range []string{"article", "main", `[role="main"]`} {
+		found := doc.Find(sel)
+		if found.Length() != 1 {
+			continue
+		}
+		if utf8.RuneCountInString(normalizeSpace(found.Text())) >= minArticleLength {
+			return []*html.Node{found.Get(0)}
+		}
+	}
+
+	top, scores := bestCandidate(doc)
+	if top == nil {
+		body := doc.Find("body")
+		if body.Length() == 0 {
+			return nil
+		}
+		return []*html.Node{body.Get(0)}
+	}
+
+	// siblings are often parts of the same article split by ads or images
+	nodes := make([]*html.Node, 0, 4)
+	threshold := scores[top] * 0.2
+	if threshold < 10 {
+		threshold = 10
+	}
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+	for sib := top.Parent.FirstChild; sib != nil; sib = sib.NextSibling {
+		if sib == top {
+			nodes = append(nodes, sib)
+			continue
+		}
+		if sib.Type != html.ElementNode {
+			continue
+		}
+		if scores[sib] >= threshold {
+			nodes = append(nodes, sib)
+			continue
+		}
+
+		if sib.DataAtom == atom.P {
+			text := normalizeSpace(goquery.NewDocumentFromNode(sib).Text())
+			if utf8.RuneCountInString(text) > 80 && linkDensity(sib) < 0.25 {
+				nodes = append(nodes, sib)
+			}
+		}
+	}
+
+	return nodes
+}
+
+// bestCandidate scores parents of the text blocks by the amount of text they hold
+func bestCandidate(doc *goquery.Document) (*html.Node, map[*html.Node]float64) {
+	scores := make(map[*html.Node]float64)
+	candidates := make([]*html.Node, 0, 16)
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	doc.Find("p, pre, td, blockquote, li, h2, h3, div").Each(func(i int, s *goquery.Selection) {
+		n := s.Get(0)
+		// divs count only when they hold text directly and not in nested blocks
+		if n.DataAtom == atom.Div && hasBlockChild(n) {
+			return
+		}
+
+		text := normalizeSpace(s.Text())
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphLength {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + float64(strings.Count(text, "，"))
+		score += math.Min(float64(length)/100, 3)
+
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+	})
+
+	if len(candidates) == 0 {
+		return nil, scores
+	}
+
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+
+	return candidates[0], scores
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score = 10
+	case atom.Div, atom.Section:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+
+	name := className(goquery.NewDocumentFromNode(n).Selection)
+	if negativeRe.MatchString(name) {
+		score -= 25
+	}
+	if positiveRe.MatchString(name) {
+		score += 25
+	}
+
+	return score
+}
+
+// blockTexts walks the node and returns text of each block element as a separate paragraph
+func blockTexts(root *html.Node) []string {
+	out := make([]string, 0, 16)
+	var buf strings.Builder
+
+	flush := func() {
+		text := normalizeSpace(buf.String())
+		buf.Reset()
+		if text != "" {
+			out = append(out, text)
+		}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			buf.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if junkTags[n.DataAtom] {
+				return
+			}
+			// link lists inside the article are menus or "read also" blocks
+			if (n.DataAtom == atom.Ul || n.DataAtom == atom.Ol || n.DataAtom == atom.Div) && linkDensity(n) > maxLinkDensity {
+				return
+			}
+		}
+
+		block := n.Type == html.ElementNode && blockTags[n.DataAtom]
+		if block {
+			flush()
+			if n.DataAtom == atom.Li {
+				buf.WriteString("- ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(root)
+	flush()
+
+	return out
+}
+
+// dropTitle removes the heading that repeats the page title
+func dropTitle(paragraphs []string, title string) []string {
+	if len(paragraphs) == 0 || title == "" {
+		return paragraphs
+	}
+	if strings.EqualFold(paragraphs[0], title) {
+		return paragraphs[1:]
+	}
+	return paragraphs
+}
+
+func linkDensity(n *html.Node) float64 {
+	s := goquery.NewDocumentFromNode(n).Selection
+	length := utf8.RuneCountInString(normalizeSpace(s.Text()))
+	if length == 0 {
+		return 0
+	}
+
+	var linkLength int
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(normalizeSpace(a.Text()))
+	})
+
+	return float64(linkLength) / float64(length)
+}
+
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.DataAtom] && c.DataAtom != atom.Br {
+			return true
+		}
+	}
+	return false
+}
+
+func isHidden(s *goquery.Selection) bool {
+	if _, ok := s.Attr("hidden"); ok {
+		return true
+	}
+	if v, _ := s.Attr("aria-hidden"); v == "true" {
+		return true
+	}
+	style, _ := s.Attr("style")
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// isPinned tells elements placed over the page by their inline style
+func isPinned(s *goquery.Selection) bool {
+	style, _ := s.Attr("style")
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	return strings.Contains(style, "position:fixed")
+}
+
+// isChallenge tells bot protection pages, they have no content of the page asked for
+func isChallenge(doc *goquery.Document) bool {
+	if doc.Find(challengeSelector).Length() > 0 {
+		return true
+	}
+	return challengeTitles[strings.ToLower(normalizeSpace(doc.Find("title").First().Text()))]
+}
+
+func className(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return class + " " + id
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	pb "url-saver-bot/internal/proto"
+	"url-saver-bot/internal/storage"
+)
+
+const (
+	reclassifyInterval  = 10 * time.Minute
+	reclassifyBatchSize = 20
+	// reclassifyRequeue is when a page sent to the worker is sent again if it still has the old model,
+	// e.g. the classifier kept failing or the bot restarted before the worker got to it
+	reclassifyRequeue = time.Hour
+)
+
+// Reclassifier sends pages tagged by an older model back to the tag worker.
+// Only a batch of pages is sent per interval, so the worker is not flooded after a deploy.
+// The pages keep their status, the reclassifier remembers what it has sent instead of marking them.
+type Reclassifier struct {
+	worker  *TagWorker
+	storage storage.Storage
+	queued  map[int]time.Time
+	ctx     context.Context
+}
+
+func NewReclassifier(ctx context.Context, s storage.Storage, w *TagWorker) *Reclassifier {
+	return &Reclassifier{
+		worker:  w,
+		storage: s,
+		queued:  make(map[int]time.Time),
+		ctx:     ctx,
+	}
+}
+
+func (r *Reclassifier) Start() {
+	ticker := time.NewTicker(reclassifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reclassify(); err != nil {
+				log.Printf("[ERR] reclassifier: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Reclassifier) reclassify() error {
+	info, err := r.worker.classifier.Info(r.ctx, &pb.InfoRequest{})
+	if err != nil {
+		return fmt.Errorf("can't get classifier info: %w", err)
+	}
+
+	now := time.Now()
+	for id, sent := range r.queued {
+		if now.Sub(sent) > reclassifyRequeue {
+			delete(r.queued, id)
+		}
+	}
+
+	// the pages still waiting in the worker queue are selected again, so they are skipped over the batch
+	pages, err := r.storage.SelectOutdated(r.ctx, info.Classifier, info.ModelVersion, reclassifyBatchSize+len(r.queued))
+	if err != nil {
+		return fmt.Errorf("can't get outdated pages: %w", err)
+	}
+
+	sent := 0
+	for _, p := range pages {
+		if _, ok := r.queued[p.ID]; ok || sent == reclassifyBatchSize {
+			continue
+		}
+		r.queued[p.ID] = now
+		r.worker.ReclassifyPage(p)
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("reclassifier: %d pages sent for tagging with model %v", sent, info.ModelVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"context"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+var redditPostRe = regexp.MustCompile(`^/r/[^/]+/comments/[^/]+`)
+
+// redditListing is one listing of the post .json response: the post itself, then comments
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Data struct {
+				Title     string `json:"title"`
+				Author    string `json:"author"`
+				Selftext  string `json:"selftext"`
+				Subreddit string `json:"subreddit_name_prefixed"`
+				Domain    string `json:"domain"`
+				IsSelf    bool   `json:"is_self"`
+			} `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// extractReddit reads the post from the JSON version of its page
+func extractReddit(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error) {
+	if !redditPostRe.MatchString(u.Path) {
+		return Article{}, NewUnsupportedURLError()
+	}
+
+	var listings []redditListing
+	if err := getJSON(ctx, f, "https://www.reddit.com"+strings.TrimSuffix(u.EscapedPath(), "/")+".json?raw_json=1&limit=1", &listings); err != nil {
+		return Article{}, err
+	}
+	if len(listings) == 0 || len(listings[0].Data.Children) == 0 {
+		return Article{}, NewNoDataError()
+	}
+	post := listings[0].Data.Children[0].Data
+
+	var link string
+	if !post.IsSelf && post.Domain != "" {
+		link = "Link to " + post.Domain
+	}
+
+	return Article{
+		Title:  post.Title,
+		Byline: post.Author,
+		Body:   joinParagraphs(post.Subreddit, textParagraphs(post.Selftext), link),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxAttempts    = 5
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = 10 * time.Minute
+)
+
+// isTransient reports whether the failure may go away by itself,
+// so the page is worth another try later
+func isTransient(err error) bool {
+	var be *BlockedError
+	if errors.As(err, &be) {
+		return false
+	}
+
+	var hb *HostBusyError
+	if errors.As(err, &hb) {
+		return true
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= http.StatusInternalServerError || se.Code == http.StatusTooManyRequests
+	}
+
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Unavailable {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryDelay doubles the delay on each attempt starting from baseRetryDelay
+func retryDelay(attempt int) time.Duration {
+	d := baseRetryDelay << attempt
+	if d > maxRetryDelay || d <= 0 {
+		return maxRetryDelay
+	}
+	return d
+}
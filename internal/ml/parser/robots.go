@@ -0,0 +1,244 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robots checks robots.txt rules for our User-Agent. Rules are cached per host
+// for ttl, so every host's robots.txt is requested at most once per ttl.
+type robots struct {
+	fetcher *Fetcher
+	agent   string
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]robotsEntry
+}
+
+type robotsEntry struct {
+	rules   []robotsRule
+	expires time.Time
+}
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// robotsLoadKey marks the context of robots.txt requests
+type robotsLoadKey struct{}
+
+func loadingRobots(ctx context.Context) bool {
+	return ctx.Value(robotsLoadKey{}) != nil
+}
+
+func newRobots(f *Fetcher, userAgent string, ttl time.Duration) *robots {
+	return &robots{
+		fetcher: f,
+		agent:   agentToken(userAgent),
+		ttl:     ttl,
+		cache:   make(map[string]robotsEntry),
+	}
+}
+
+// allowed reports whether the URL may be crawled. Missing robots.txt allows everything,
+// server errors are returned so the page is tried later.
+func (r *robots) allowed(ctx context.Context, u *url.URL) (bool, error) {
+	key := u.Scheme + "://" + u.Host
+
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+
+	if !ok || time.Now().After(entry.expires) {
+		rules, err := r.load(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		entry = robotsEntry{rules: rules, expires: time.Now().Add(r.ttl)}
+
+		r.mu.Lock()
+		r.cache[key] = entry
+		r.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return matchRobots(entry.rules, path), nil
+}
+
+func (r *robots) load(ctx context.Context, base string) ([]robotsRule, error) {
+	resp, err := r.fetcher.do(context.WithValue(ctx, robotsLoadKey{}, true), http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode >= 500:
+		return nil, NewStatusError(resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return nil, nil
+	}
+
+	return parseRobots(resp.Body, r.agent), nil
+}
+
+// parseRobots returns rules of the group for our agent or of the "*" group if there is none
+func parseRobots(body []byte, agent string) []robotsRule {
+	var (
+		own, common        []robotsRule
+		hasOwn             bool
+		groupOwn, groupAll bool
+		inAgents           bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share one group
+			if !inAgents {
+				groupOwn, groupAll = false, false
+			}
+			inAgents = true
+
+			// the product token is compared whole, "bot" is not a group for "url-saver-bot"
+			ua := agentToken(value)
+			if ua == "*" {
+				groupAll = true
+			} else if agent != "" && ua == agent {
+				groupOwn = true
+				hasOwn = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			// empty disallow allows everything and adds no rule
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", path: value}
+			if groupOwn {
+				own = append(own, rule)
+			}
+			if groupAll {
+				common = append(common, rule)
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	if hasOwn {
+		return own
+	}
+	return common
+}
+
+// matchRobots applies the most specific matching rule, allow wins on equal length
+func matchRobots(rules []robotsRule, path string) bool {
+	allowed, best := true, -1
+	for _, r := range rules {
+		if !robotsPathMatch(r.path, path) {
+			continue
+		}
+		if l := len(r.path); l > best || (l == best && r.allow) {
+			allowed, best = r.allow, l
+		}
+	}
+	return allowed
+}
+
+// robotsPathMatch supports "*" wildcards and the "$" end anchor
+func robotsPathMatch(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+
+	if anchored {
+		last := parts[len(parts)-1]
+		return pos == len(path) || (len(parts) > 1 && strings.HasSuffix(path, last))
+	}
+	return true
+}
+
+// agentToken takes the product name from the User-Agent: "url-saver-bot/1.0 (...)" -> "url-saver-bot"
+func agentToken(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	if len(fields) == 0 {
+		return ""
+	}
+	token, _, _ := strings.Cut(fields[0], "/")
+	return strings.ToLower(token)
+}
+
+// robotsDirectives reads X-Robots-Tag headers addressed to everyone or to our agent
+func robotsDirectives(header http.Header, agent string) (noIndex bool, noArchive bool) {
+	for _, v := range header.Values("X-Robots-Tag") {
+		// "otherbot: noindex" is not for us
+		if name, rest, ok := strings.Cut(v, ":"); ok && !strings.ContainsAny(name, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != agent && !isRobotsDirective(name) {
+				continue
+			}
+			if name == agent {
+				v = rest
+			}
+		}
+
+		for _, d := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(d)) {
+			case "noindex":
+				noIndex = true
+			case "noarchive":
+				noArchive = true
+			case "none":
+				noIndex, noArchive = true, true
+			}
+		}
+	}
+	return noIndex, noArchive
+}
+
+func isRobotsDirective(s string) bool {
+	switch s {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+	return false
+}
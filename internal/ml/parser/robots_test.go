@@ -0,0 +1,33 @@
+package parser
+
+import "testing"
+
+func TestRobotsGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		allowed bool
+	}{
+		{"shorter token is another bot", "User-agent: bot\nDisallow: /\n", "/page", true},
+		{"longer token is another bot", "User-agent: url-saver-bot-pro\nDisallow: /\n", "/page", true},
+		{"own group", "User-agent: url-saver-bot\nDisallow: /private\n", "/private/a", false},
+		{"own group in another case", "User-agent: URL-Saver-Bot\nDisallow: /\n", "/page", false},
+		{"own group with a version", "User-agent: url-saver-bot/2.0\nDisallow: /\n", "/page", false},
+		{"own group wins over everyone", "User-agent: *\nDisallow: /\n\nUser-agent: url-saver-bot\nAllow: /\n", "/page", true},
+		{"group of several agents", "User-agent: other\nUser-agent: url-saver-bot\nDisallow: /a\n", "/a", false},
+		{"everyone", "User-agent: *\nDisallow: /tmp\n", "/tmp/x", false},
+		{"longest rule wins", "User-agent: *\nDisallow: /a\nAllow: /a/b\n", "/a/b/c", true},
+		{"end anchor", "User-agent: *\nDisallow: /*.pdf$\n", "/file.pdf", false},
+		{"end anchor on a longer path", "User-agent: *\nDisallow: /*.pdf$\n", "/file.pdf?x=1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots([]byte(tt.robots), agentToken("url-saver-bot/1.0 (+https://example.com)"))
+			if got := matchRobots(rules, tt.path); got != tt.allowed {
+				t.Errorf("got allowed %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// minSiteTextLength is lower than minTextLength: a tweet or a video with a short
+// description is still better described by its metadata than by nothing
+const minSiteTextLength = 20
+
+// siteExtractor gets the article of a known site from its API or page metadata,
+// where the generic parser finds little because the page is built by scripts
+type siteExtractor struct {
+	name    string
+	hosts   []string
+	extract func(ctx context.Context, f *Fetcher, u *neturl.URL) (Article, error)
+}
+
+var siteExtractors = []siteExtractor{
+	{name: "youtube", hosts: []string{"youtube.com", "youtu.be"}, extract: extractYouTube},
+	{name: "twitter", hosts: []string{"twitter.com", "x.com"}, extract: extractTweet},
+	{name: "github", hosts: []string{"github.com"}, extract: extractGitHub},
+	{name: "reddit", hosts: []string{"reddit.com"}, extract: extractReddit},
+	{name: "arxiv", hosts: []string{"arxiv.org"}, extract: extractArxiv},
+}
+
+// siteExtractorFor matches the URL host and its subdomains against the registry
+func siteExtractorFor(u *neturl.URL) *siteExtractor {
+	host := strings.ToLower(u.Hostname())
+	for i, s := range siteExtractors {
+		for _, h := range s.hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return &siteExtractors[i]
+			}
+		}
+	}
+	return nil
+}
+
+// parseSite runs the site extractor if there is one for the URL. ok is false
+// when the generic parser should be used: no extractor, or it failed or found too little.
+func (p parser) parseSite(ctx context.Context, url string) (a Article, ok bool, err error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return Article{}, false, nil
+	}
+	site := siteExtractorFor(u)
+	if site == nil {
+		return Article{}, false, nil
+	}
+
+	// APIs live on other hosts, the robots.txt rules of the page itself decide
+	if err = p.fetcher.checkRobots(ctx, url); err != nil {
+		return Article{}, false, err
+	}
+
+	a, err = site.extract(ctx, p.fetcher, u)
+	if err != nil {
+		var ue *UnsupportedURLError
+		if !errors.As(err, &ue) {
+			log.Printf("%v extractor failed for %v, using generic parser: %v", site.name, url, err)
+		}
+		return Article{}, false, nil
+	}
+	if len(p.text(a)) < minSiteTextLength {
+		return Article{}, false, nil
+	}
+
+	a.URL = url
+	if a.ContentType == "" {
+		a.ContentType = htmlType
+	}
+	return a, true, nil
+}
+
+// apiGet requests the API endpoint of the site. The endpoints are not pages,
+// so robots.txt is not checked, but the guard and host limits apply.
+func apiGet(ctx context.Context, f *Fetcher, url string, accept string) ([]byte, error) {
+	resp, err := f.do(ctx, http.MethodGet, url, http.Header{"Accept": []string{accept}})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, NewStatusError(resp.StatusCode)
+	}
+	if resp.Truncated {
+		return nil, fmt.Errorf("response of %v is too big", url)
+	}
+	return resp.Body, nil
+}
+
+func getJSON(ctx context.Context, f *Fetcher, url string, v any) error {
+	body, err := apiGet(ctx, f, url, "application/json")
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("can't decode response of %v: %w", url, err)
+	}
+	return nil
+}
+
+// joinParagraphs drops empty paragraphs
+func joinParagraphs(paragraphs ...string) string {
+	out := make([]string, 0, len(paragraphs))
+	for _, p := range paragraphs {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// textParagraphs splits plain or markdown text into paragraphs with normalized spaces
+func textParagraphs(text string) string {
+	parts := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n")
+	for i, p := range parts {
+		parts[i] = normalizeSpace(p)
+	}
+	return joinParagraphs(parts...)
+}
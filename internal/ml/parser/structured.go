@@ -0,0 +1,339 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"url-saver-bot/internal/storage"
+)
+
+const (
+	maxTagCandidates = 10
+	// maxTagLength is the longest tag in bytes, it fits into a Telegram button's callback data
+	maxTagLength = 64
+)
+
+// typeFamilies maps schema.org types to the content type facet pages are filtered by
+var typeFamilies = map[string]string{
+	"article":              "Article",
+	"newsarticle":          "Article",
+	"analysisnewsarticle":  "Article",
+	"opinionnewsarticle":   "Article",
+	"reportagenewsarticle": "Article",
+	"blogposting":          "Article",
+	"liveblogposting":      "Article",
+	"socialmediaposting":   "Article",
+	"techarticle":          "Article",
+	"scholarlyarticle":     "Article",
+	"report":               "Article",
+	"recipe":               "Recipe",
+	"product":              "Product",
+	"productgroup":         "Product",
+	"individualproduct":    "Product",
+	"videoobject":          "VideoObject",
+	"clip":                 "VideoObject",
+	"movie":                "VideoObject",
+}
+
+// ignoredTypes describe the site rather than the page content
+var ignoredTypes = map[string]bool{
+	"website":                 true,
+	"webpage":                 true,
+	"organization":            true,
+	"person":                  true,
+	"breadcrumblist":          true,
+	"imageobject":             true,
+	"searchaction":            true,
+	"sitenavigationelement":   true,
+	"wpheader":                true,
+	"wpfooter":                true,
+	"wpsidebar":               true,
+	"listitem":                true,
+	"itemlist":                true,
+	"collectionpage":          true,
+	"readaction":              true,
+	"corporation":             true,
+	"localbusiness":           true,
+	"newsmediaorganization":   true,
+	"educationalorganization": true,
+}
+
+// ldItem is a JSON-LD node, fields differ between sites so they are decoded by hand
+type ldItem map[string]any
+
+// extractMetadata reads schema.org data from JSON-LD scripts, or from microdata when there is none
+func extractMetadata(doc *goquery.Document) storage.Metadata {
+	items := make([]ldItem, 0, 8)
+	doc.Find(`script[type="application/ld+json" i]`).Each(func(i int, s *goquery.Selection) {
+		var v any
+		// sites put broken JSON there too, such scripts are skipped
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &v); err == nil {
+			items = flattenLD(v, items)
+		}
+	})
+
+	if item := primaryItem(items); item != nil {
+		return ldMetadata(item)
+	}
+	return microdata(doc)
+}
+
+// flattenLD collects nodes of arrays, @graph and mainEntity
+func flattenLD(v any, items []ldItem) []ldItem {
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t {
+			items = flattenLD(e, items)
+		}
+	case map[string]any:
+		items = append(items, t)
+		if g, ok := t["@graph"]; ok {
+			items = flattenLD(g, items)
+		}
+		if m, ok := t["mainEntity"]; ok {
+			items = flattenLD(m, items)
+		}
+	}
+	return items
+}
+
+// primaryItem prefers known content types and skips nodes describing the site
+func primaryItem(items []ldItem) ldItem {
+	var other ldItem
+	for _, item := range items {
+		for _, t := range ldStrings(item["@type"]) {
+			key := strings.ToLower(t)
+			if _, ok := typeFamilies[key]; ok {
+				return item
+			}
+			if other == nil && !ignoredTypes[key] {
+				other = item
+			}
+		}
+	}
+	return other
+}
+
+func ldMetadata(item ldItem) storage.Metadata {
+	var m storage.Metadata
+	types := ldStrings(item["@type"])
+	m.Type = schemaType(firstString(types))
+	for _, t := range types {
+		if _, ok := typeFamilies[strings.ToLower(t)]; ok {
+			m.Type = schemaType(t)
+			break
+		}
+	}
+
+	m.Headline = firstString(ldStrings(item["headline"]))
+	if m.Headline == "" {
+		m.Headline = firstString(ldStrings(item["name"]))
+	}
+	m.Author = strings.Join(ldNames(item["author"]), ", ")
+	m.Published = parseDate(firstString(ldStrings(item["datePublished"])))
+	m.Section = firstString(ldStrings(item["articleSection"]))
+	if m.Section == "" {
+		m.Section = firstString(ldStrings(item["recipeCategory"]))
+	}
+	m.Keywords = splitKeywords(ldStrings(item["keywords"]))
+
+	return m
+}
+
+// ldStrings returns a string or strings of an array, other values are skipped
+func ldStrings(v any) []string {
+	switch t := v.(type) {
+	case string:
+		if s := normalizeSpace(t); s != "" {
+			return []string{s}
+		}
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			out = append(out, ldStrings(e)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// ldNames reads authors given as a name, a Person node or an array of both
+func ldNames(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return ldStrings(t)
+	case map[string]any:
+		return ldStrings(t["name"])
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			out = append(out, ldNames(e)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// microdata reads the first itemscope of a known type, or the first one describing the content
+func microdata(doc *goquery.Document) storage.Metadata {
+	var scope, other *goquery.Selection
+	doc.Find(`[itemscope][itemtype]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		t := schemaType(s.AttrOr("itemtype", ""))
+		key := strings.ToLower(t)
+		if _, ok := typeFamilies[key]; ok {
+			scope = s
+			return false
+		}
+		if other == nil && !ignoredTypes[key] {
+			other = s
+		}
+		return true
+	})
+	if scope == nil {
+		scope = other
+	}
+	if scope == nil {
+		return storage.Metadata{}
+	}
+
+	props := itemProps(scope)
+	m := storage.Metadata{
+		Type:      schemaType(scope.AttrOr("itemtype", "")),
+		Headline:  firstString(props["headline"]),
+		Author:    strings.Join(props["author"], ", "),
+		Published: parseDate(firstString(props["datePublished"])),
+		Section:   firstString(props["articleSection"]),
+		Keywords:  splitKeywords(props["keywords"]),
+	}
+	if m.Headline == "" {
+		m.Headline = firstString(props["name"])
+	}
+	if m.Section == "" {
+		m.Section = firstString(props["recipeCategory"])
+	}
+	return m
+}
+
+// itemProps collects properties of the scope, leaving out properties of nested scopes.
+// A nested scope as a value, like an author Person, gives its name.
+func itemProps(scope *goquery.Selection) map[string][]string {
+	props := make(map[string][]string)
+	scope.Find("[itemprop]").Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered("[itemscope]").First().Get(0) != scope.Get(0) {
+			return
+		}
+
+		value := itemValue(s)
+		if _, nested := s.Attr("itemscope"); nested {
+			value = firstString(itemProps(s)["name"])
+			if value == "" {
+				value = normalizeSpace(s.Text())
+			}
+		}
+		if value == "" {
+			return
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			props[name] = append(props[name], value)
+		}
+	})
+	return props
+}
+
+func itemValue(s *goquery.Selection) string {
+	n := s.Get(0)
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	switch n.Data {
+	case "meta":
+		return normalizeSpace(s.AttrOr("content", ""))
+	case "time":
+		if v, ok := s.Attr("datetime"); ok {
+			return normalizeSpace(v)
+		}
+	case "a", "link":
+		return s.AttrOr("href", "")
+	case "img":
+		return s.AttrOr("src", "")
+	}
+	if v, ok := s.Attr("content"); ok {
+		return normalizeSpace(v)
+	}
+	return normalizeSpace(s.Text())
+}
+
+// schemaType takes the type name from "https://schema.org/NewsArticle" and maps it to its facet
+func schemaType(t string) string {
+	// microdata may list several types, the first one is the main
+	if fields := strings.Fields(t); len(fields) > 0 {
+		t = fields[0]
+	}
+	if i := strings.LastIndexAny(t, "/:#"); i >= 0 {
+		t = t[i+1:]
+	}
+	if family, ok := typeFamilies[strings.ToLower(t)]; ok {
+		return family
+	}
+	return t
+}
+
+// splitKeywords accepts "a, b, c" strings and arrays
+func splitKeywords(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := make(map[string]bool)
+	for _, v := range values {
+		for _, k := range strings.Split(v, ",") {
+			k = normalizeSpace(k)
+			if k == "" || seen[strings.ToLower(k)] {
+				continue
+			}
+			seen[strings.ToLower(k)] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func parseDate(s string) time.Time {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func firstString(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// TagCandidates are tags suggested by the page itself: its section first, then keywords.
+// Longer keywords are phrases rather than tags and are left out.
+func TagCandidates(m storage.Metadata) []string {
+	candidates := make([]string, 0, len(m.Keywords)+1)
+	for _, c := range splitKeywords(append([]string{m.Section}, m.Keywords...)) {
+		if c = strings.ToLower(c); len(c) <= maxTagLength {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) > maxTagCandidates {
+		candidates = candidates[:maxTagCandidates]
+	}
+	return candidates
+}
@@ -4,39 +4,66 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"log"
 	"sync"
 	"time"
+	"url-saver-bot/internal/archive"
 	pb "url-saver-bot/internal/proto"
 	"url-saver-bot/internal/storage"
 )
 
 const (
-	noDataTag = "page have no data"
-	errorTag  = "error while parsing page"
+	classifierAddress = ":3233"
+	noDataReason      = "page have no data"
+	mediaTag          = "media"
 )
 
+// Classifier predicts page tags. It is the gRPC client of the BERT server, tests use a fake one.
+type Classifier interface {
+	Predict(ctx context.Context, in *pb.PredictRequest, opts ...grpc.CallOption) (*pb.PredictResponse, error)
+	Info(ctx context.Context, in *pb.InfoRequest, opts ...grpc.CallOption) (*pb.InfoResponse, error)
+}
+
 type TagWorker struct {
-	buff        []storage.Page
+	classifier  Classifier
+	buff        []task
 	maxBuffSize int
-	ch          chan storage.Page
+	ch          chan task
 	errChan     chan error
 	ticker      *time.Ticker
 	parser      parser
 	storage     storage.Storage
+	archiver    *archive.Archiver
+	cacheTTL    time.Duration
+	inFlight    singleflight.Group
 	ctx         context.Context
 }
 
-func NewTagWorker(ctx context.Context, s storage.Storage, maxBufferSize int) *TagWorker {
+// task is a page waiting for tags together with the number of failed tries.
+// Reclassified pages get a new prediction even if the cached one is fresh.
+type task struct {
+	page       storage.Page
+	attempt    int
+	reclassify bool
+	// untagged pages are fetched for their title, length and snapshot, the tag is left to the user
+	untagged bool
+}
+
+func NewTagWorker(ctx context.Context, s storage.Storage, f *Fetcher, a *archive.Archiver, c Classifier,
+	maxBufferSize int, cacheTTL time.Duration) *TagWorker {
 	w := &TagWorker{
-		buff:        make([]storage.Page, 0, maxBufferSize),
+		classifier:  c,
+		buff:        make([]task, 0, maxBufferSize),
 		maxBuffSize: maxBufferSize,
-		ch:          make(chan storage.Page),
+		ch:          make(chan task),
 		errChan:     make(chan error),
-		parser:      NewParser(),
+		parser:      NewParser(f),
 		storage:     s,
+		archiver:    a,
+		cacheTTL:    cacheTTL,
 		ticker:      time.NewTicker(3 * time.Second),
 		ctx:         ctx,
 	}
@@ -47,11 +74,11 @@ func NewTagWorker(ctx context.Context, s storage.Storage, maxBufferSize int) *Ta
 			case val := <-w.ch:
 				w.buff = append(w.buff, val)
 				if len(w.buff) == w.maxBuffSize {
-					go w.processPages()
+					go w.processPages(w.flush())
 				}
 			case <-w.ticker.C:
 				if len(w.buff) > 0 {
-					go w.processPages()
+					go w.processPages(w.flush())
 				}
 			}
 		}
@@ -66,54 +93,239 @@ func NewTagWorker(ctx context.Context, s storage.Storage, maxBufferSize int) *Ta
 }
 
 func (w *TagWorker) AppendPage(page storage.Page) {
-	w.ch <- page
+	w.ch <- task{page: page}
 }
 
-func (w *TagWorker) processPages() {
-	pages := w.buff
-	w.buff = w.buff[:0]
+// AppendUntagged queues the page of a user who tags pages by hand
+func (w *TagWorker) AppendUntagged(page storage.Page) {
+	w.ch <- task{page: page, untagged: true}
+}
 
-	conn, err := grpc.Dial(":3233", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		log.Println(err)
-	}
-	defer conn.Close()
-	client := pb.NewBertClassifierClient(conn)
-
-	var wg sync.WaitGroup
-	wg.Add(len(pages))
-	for i := 0; i < len(pages); i++ {
-		go func(page *storage.Page) {
-			tag := ""
-			text, err := w.parser.parse(page.URL)
-			var e *NoDataError
-			if errors.As(err, &e) {
-				tag = noDataTag
-			} else if err != nil {
-				tag = errorTag
-				w.errChan <- err
-			}
+// ReclassifyPage queues the page for a new prediction with the current model
+func (w *TagWorker) ReclassifyPage(page storage.Page) {
+	w.ch <- task{page: page, reclassify: true}
+}
 
-			req := &pb.PredictRequest{Text: text}
-			resp, err := client.Predict(w.ctx, req)
+func (w *TagWorker) flush() []task {
+	tasks := w.buff
+	w.buff = make([]task, 0, w.maxBuffSize)
+	return tasks
+}
+
+// retry puts the task back into the queue after a backoff delay
+func (w *TagWorker) retry(t task) {
+	t.attempt++
+	time.AfterFunc(retryDelay(t.attempt), func() {
+		w.ch <- t
+	})
+}
+
+func (w *TagWorker) processPages(tasks []task) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		pages = make([]storage.Page, 0, len(tasks))
+	)
+	wg.Add(len(tasks))
+	for _, t := range tasks {
+		go func(t task) {
+			defer wg.Done()
+
+			page := t.page
+			err := w.tag(w.classifier, t, &page)
 			if err != nil {
-				tag = noDataTag
-				w.errChan <- err
-			}
-			if tag == "" {
-				tag = resp.Prediction
+				w.errChan <- fmt.Errorf("can't tag %v (attempt %d): %w", t.page.URL, t.attempt+1, err)
+				if isTransient(err) && t.attempt+1 < maxAttempts {
+					w.retry(t)
+					return
+				}
+				page.Status = failureStatus(err)
+				page.StatusReason = failureReason(err)
 			}
-			page.Tags = tag
 
-			wg.Done()
-		}(&pages[i])
+			mu.Lock()
+			pages = append(pages, page)
+			mu.Unlock()
+		}(t)
 	}
 	wg.Wait()
 
+	if len(pages) == 0 {
+		return
+	}
+
 	go func(pages []storage.Page) {
-		err := w.storage.BatchUpdate(w.ctx, pages)
+		err := w.storage.UpdateTagged(w.ctx, pages)
 		if err != nil {
 			w.errChan <- fmt.Errorf("tag worker update error: %w", err)
 		}
 	}(pages)
 }
+
+// tag fills page tags from the content cache or from the classifier.
+// Pages with the same URL tagged at the same time share one fetch and prediction.
+func (w *TagWorker) tag(client Classifier, t task, page *storage.Page) error {
+	key := CanonicalURL(page.URL)
+	// untagged pages don't wait for a prediction and don't share a missing one
+	flight := key
+	if t.untagged {
+		flight += " untagged"
+	}
+	v, err, _ := w.inFlight.Do(flight, func() (any, error) {
+		return w.content(client, key, t)
+	})
+	if err != nil {
+		return err
+	}
+
+	c := v.(*storage.Content)
+	page.ContentType = c.ContentType
+	page.Title = c.Title
+	page.Metadata = c.Metadata
+	page.Language = c.Language
+	page.WordCount = c.WordCount
+	page.ReadingTime = c.ReadingTime
+	if t.untagged {
+		page.Status = storage.StatusTagged
+		page.StatusReason = ""
+	} else {
+		setTag(page, c.Tags, c.TagSource)
+		page.Classifier = c.Classifier
+		page.ModelVersion = c.ModelVersion
+	}
+
+	if c.SnapshotKey != "" {
+		err = w.archiver.Attach(w.ctx, &storage.Snapshot{
+			URL:      page.URL,
+			UserName: page.UserName,
+			Key:      c.SnapshotKey,
+			Size:     c.SnapshotSize,
+			Created:  time.Now(),
+		})
+		if err != nil {
+			w.errChan <- fmt.Errorf("can't keep snapshot of %v: %w", page.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// predict parses the page and classifies it unless the page is left untagged
+func (w *TagWorker) predict(client Classifier, url string, v Validators, untagged bool) (*storage.Content, error) {
+	article, validators, err := w.parser.parse(w.ctx, url, v)
+
+	c := &storage.Content{
+		Title:        article.Title,
+		Byline:       article.Byline,
+		Body:         article.Body,
+		ContentType:  article.ContentType,
+		ETag:         validators.ETag,
+		LastModified: validators.LastModified,
+		Metadata:     article.Metadata,
+		Language:     article.Language,
+	}
+	c.WordCount, c.ReadingTime = articleLength(article)
+	// the site doesn't allow to keep its text, only the prediction is cached
+	if article.NoIndex || article.NoArchive {
+		c.Body = ""
+	}
+
+	// there is nothing to classify in images and videos, their type is the tag
+	var me *MediaError
+	if errors.As(err, &me) {
+		c.Tags = mediaTag
+		c.TagSource = storage.TagSourceParser
+		return c, nil
+	}
+	// a page with too little text may still name its section or keywords
+	var nd *NoDataError
+	if candidates := TagCandidates(article.Metadata); errors.As(err, &nd) && len(candidates) > 0 {
+		c.Tags = candidates[0]
+		c.TagSource = storage.TagSourceParser
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !untagged {
+		if err = w.classify(client, c, article); err != nil {
+			return nil, err
+		}
+	}
+	w.snapshot(c, article)
+	return c, nil
+}
+
+// snapshot stores the offline copy of the page unless the site forbids it
+func (w *TagWorker) snapshot(c *storage.Content, article Article) {
+	if article.NoArchive {
+		return
+	}
+
+	key, size, err := w.archiver.Create(w.ctx, archive.Page{
+		URL:   article.URL,
+		Title: article.Title,
+		HTML:  article.HTML,
+		Text:  article.Body,
+	}, time.Now())
+	if err != nil {
+		w.errChan <- fmt.Errorf("can't create snapshot of %v: %w", article.URL, err)
+		return
+	}
+	c.SnapshotKey = key
+	c.SnapshotSize = size
+}
+
+func (w *TagWorker) classify(client Classifier, c *storage.Content, article Article) error {
+	resp, err := client.Predict(w.ctx, &pb.PredictRequest{Text: w.parser.text(article), Language: article.Language})
+	if err != nil {
+		return fmt.Errorf("can't predict tag: %w", err)
+	}
+
+	c.Tags = resp.Prediction
+	c.TagSource = storage.TagSourceML
+	c.Classifier = resp.Classifier
+	c.ModelVersion = resp.ModelVersion
+	return nil
+}
+
+func setTag(page *storage.Page, tag string, source storage.TagSource) {
+	page.Tags = tag
+	page.TagSource = source
+	page.Classifier = ""
+	page.ModelVersion = ""
+	page.Status = storage.StatusTagged
+	page.StatusReason = ""
+}
+
+// DialClassifier connects to the BERT server, the connection is made lazily and restored when lost
+func DialClassifier() (*grpc.ClientConn, error) {
+	return grpc.Dial(classifierAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+// failureStatus tells pages we refused to fetch from pages that failed
+func failureStatus(err error) storage.Status {
+	var be *BlockedError
+	var de *DisallowedError
+	if errors.As(err, &be) || errors.As(err, &de) {
+		return storage.StatusNotFetched
+	}
+	return storage.StatusFailed
+}
+
+func failureReason(err error) string {
+	var e *NoDataError
+	if errors.As(err, &e) {
+		return noDataReason
+	}
+	var be *BlockedError
+	if errors.As(err, &be) {
+		return be.Reason
+	}
+	var de *DisallowedError
+	if errors.As(err, &de) {
+		return de.Reason
+	}
+	return err.Error()
+}
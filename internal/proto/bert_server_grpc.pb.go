@@ -23,6 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type BertClassifierClient interface {
 	Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error)
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 }
 
 type bertClassifierClient struct {
@@ -42,11 +43,21 @@ func (c *bertClassifierClient) Predict(ctx context.Context, in *PredictRequest,
 	return out, nil
 }
 
+func (c *bertClassifierClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, "/main.BertClassifier/Info", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BertClassifierServer is the server API for BertClassifier service.
 // All implementations must embed UnimplementedBertClassifierServer
 // for forward compatibility
 type BertClassifierServer interface {
 	Predict(context.Context, *PredictRequest) (*PredictResponse, error)
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
 	mustEmbedUnimplementedBertClassifierServer()
 }
 
@@ -57,6 +68,9 @@ type UnimplementedBertClassifierServer struct {
 func (UnimplementedBertClassifierServer) Predict(context.Context, *PredictRequest) (*PredictResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Predict not implemented")
 }
+func (UnimplementedBertClassifierServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
 func (UnimplementedBertClassifierServer) mustEmbedUnimplementedBertClassifierServer() {}
 
 // UnsafeBertClassifierServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +102,24 @@ func _BertClassifier_Predict_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BertClassifier_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BertClassifierServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/main.BertClassifier/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BertClassifierServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BertClassifier_ServiceDesc is the grpc.ServiceDesc for BertClassifier service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,6 +131,10 @@ var BertClassifier_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Predict",
 			Handler:    _BertClassifier_Predict_Handler,
 		},
+		{
+			MethodName: "Info",
+			Handler:    _BertClassifier_Info_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/proto/bert_server.proto",
@@ -12,9 +12,93 @@ import (
 )
 
 const (
-	table = "links"
+	table          = "links"
+	contentsTable  = "contents"
+	contentColumns = "url, title, byline, body, content_type, tags, tag_source, classifier, model_version, " +
+		"etag, last_modified, fetched_time, expires_time, snapshot_key, snapshot_size, " + metadataColumns + ", " + lengthColumns
+	snapshotsTable = "snapshots"
+	settingsTable  = "user_settings"
+	remindersTable = "reminders"
+	// metadataColumns keep storage.Metadata and the language in both pages and contents tables
+	metadataColumns = "schema_type, headline, author, published_time, keywords, article_section, language"
+	// lengthColumns keep the word count and the reading time in seconds in both tables
+	lengthColumns = "word_count, reading_seconds"
+	pageColumns   = "url, user_name, tags, created_time, status, status_reason, tag_source, classifier, model_version, content_type, " +
+		"title, archived, snoozed_until, link_status, http_status, final_url, last_alive_time, checked_time, " + metadataColumns + ", " + lengthColumns
+	// selectColumns are page columns with the id, which is set by the database
+	selectColumns   = "id, " + pageColumns
+	reminderColumns = "id, user_name, chat_id, url, due_time, created_time, language"
+	settingsColumns = "user_name, chat_id, language, page_size, archive_on_get, link_preview, auto_tag, " +
+		"digest, digest_random, digest_hour, timezone, reply_language"
 )
 
+// migrations run on every start, so each statement must be idempotent
+var migrations = []string{
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS status varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS status_reason varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS tag_source varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS classifier varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS model_version varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS content_type varchar NOT NULL DEFAULT ''",
+	// tags saved before sources were recorded could only come from the classifier
+	"UPDATE " + table + " SET tag_source = 'ml', status = 'tagged' WHERE tags != '' AND tag_source = ''",
+	"CREATE TABLE IF NOT EXISTS " + contentsTable + " (url varchar primary key, title varchar, byline varchar, " +
+		"body text, content_type varchar, tags varchar, tag_source varchar, classifier varchar, model_version varchar, " +
+		"etag varchar, last_modified varchar, fetched_time timestamptz, expires_time timestamptz)",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS link_status varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS http_status integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS final_url varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS last_alive_time timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS checked_time timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS snapshot_key varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS snapshot_size bigint NOT NULL DEFAULT 0",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS schema_type varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS headline varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS author varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS published_time timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS keywords varchar[]",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS article_section varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS schema_type varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS headline varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS author varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS published_time timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS keywords varchar[]",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS article_section varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS language varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS language varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS word_count integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS reading_seconds integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS word_count integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + contentsTable + " ADD COLUMN IF NOT EXISTS reading_seconds integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS title varchar NOT NULL DEFAULT ''",
+	"CREATE TABLE IF NOT EXISTS " + snapshotsTable + " (url varchar, user_name varchar, blob_key varchar, " +
+		"size bigint, created_time timestamptz, primary key (url, user_name))",
+	"CREATE TABLE IF NOT EXISTS " + settingsTable + " (user_name varchar primary key, language varchar NOT NULL DEFAULT '')",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS archived boolean NOT NULL DEFAULT false",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS page_size integer NOT NULL DEFAULT 0",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS archive_on_get boolean NOT NULL DEFAULT false",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS link_preview boolean NOT NULL DEFAULT false",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS auto_tag boolean NOT NULL DEFAULT true",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS digest varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS digest_hour integer NOT NULL DEFAULT 9",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS timezone varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS snoozed_until timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS chat_id bigint NOT NULL DEFAULT 0",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS digest_random boolean NOT NULL DEFAULT false",
+	// digest_sent is the slot of the last digest, it isn't among settingsColumns so saving settings keeps it
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS digest_sent timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00+00'",
+	// sent reminders stay until the user presses done, snoozing makes them due again
+	"CREATE TABLE IF NOT EXISTS " + remindersTable + " (id serial primary key, user_name varchar NOT NULL, " +
+		"chat_id bigint NOT NULL, url varchar NOT NULL, due_time timestamptz NOT NULL, created_time timestamptz NOT NULL, " +
+		"sent boolean NOT NULL DEFAULT false)",
+	"CREATE INDEX IF NOT EXISTS reminders_due_idx ON " + remindersTable + " (due_time) WHERE NOT sent",
+	// blobs are deleted once no snapshot and no cached content points to them
+	"CREATE INDEX IF NOT EXISTS snapshots_blob_key_idx ON " + snapshotsTable + " (blob_key)",
+	"CREATE INDEX IF NOT EXISTS contents_snapshot_key_idx ON " + contentsTable + " (snapshot_key)",
+	"ALTER TABLE " + settingsTable + " ADD COLUMN IF NOT EXISTS reply_language varchar NOT NULL DEFAULT ''",
+	"ALTER TABLE " + remindersTable + " ADD COLUMN IF NOT EXISTS language varchar NOT NULL DEFAULT ''",
+}
+
 type DBStorage struct {
 	pool *pgxpool.Pool
 }
@@ -38,26 +122,58 @@ func NewDBStorage(ctx context.Context, DSN string) *DBStorage {
 			log.Fatal(err)
 		}
 	}
+	if err = migrate(ctx, pool); err != nil {
+		log.Fatal(err)
+	}
 	return &DBStorage{pool: pool}
 }
 
 // Save check if page already exists and save if not
 func (s *DBStorage) Save(ctx context.Context, p *storage.Page) error {
 	var u string
-	err := s.pool.QueryRow(ctx, "SELECT url FROM links WHERE url = $1 AND user_name = $2", p.URL, p.UserName).Scan(&u)
+	var archived bool
+	err := s.pool.QueryRow(ctx, "SELECT url, archived FROM links WHERE url = $1 AND user_name = $2", p.URL, p.UserName).
+		Scan(&u, &archived)
+	// an archived page saved again is back in the lists
+	if archived {
+		_, err = s.pool.Exec(ctx, "UPDATE links SET archived = false, created_time = $3 WHERE url = $1 AND user_name = $2",
+			p.URL, p.UserName, p.Created)
+		if err != nil {
+			return fmt.Errorf("storage can't save page: %w", err)
+		}
+		return nil
+	}
 	if u != "" {
 		return storage.NewAlreadyExistsError()
 	}
-	_, err = s.pool.Exec(ctx, "INSERT INTO links (url, user_name, tags, created_time) VALUES ($1, $2, $3, $4)", p.URL, p.UserName, p.Tags, p.Created)
+	_, err = s.pool.Exec(ctx, "INSERT INTO links ("+pageColumns+") "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)",
+		p.URL, p.UserName, p.Tags, p.Created, p.Status, p.StatusReason, p.TagSource, p.Classifier, p.ModelVersion,
+		p.ContentType, p.Title, p.Archived, p.SnoozedUntil, p.LinkStatus, p.HTTPStatus, p.FinalURL, p.LastAlive, p.Checked,
+		p.Metadata.Type, p.Metadata.Headline, p.Metadata.Author, p.Metadata.Published, p.Metadata.Keywords, p.Metadata.Section,
+		p.Language, p.WordCount, seconds(p.ReadingTime))
 	if err != nil {
 		return fmt.Errorf("storage can't save page: %w", err)
 	}
 	return nil
 }
 
-func (s *DBStorage) Pick(ctx context.Context, userName string) (*storage.Page, error) {
+func (s *DBStorage) Get(ctx context.Context, URL string, userName string) (*storage.Page, error) {
 	var p storage.Page
-	err := s.pool.QueryRow(ctx, "SELECT url, user_name, tags, created_time FROM links WHERE user_name = $1 ORDER BY created_time LIMIT 1", userName).Scan(&p.URL, &p.UserName, &p.Tags, &p.Created)
+	err := scanPage(s.pool.QueryRow(ctx, "SELECT "+selectColumns+" FROM links WHERE url = $1 AND user_name = $2", URL, userName), &p)
+	if err == pgx.ErrNoRows {
+		return nil, storage.NewNoResultError()
+	} else if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Pick returns the user's first saved page matching the filter
+func (s *DBStorage) Pick(ctx context.Context, userName string, f storage.Filter) (*storage.Page, error) {
+	where, args := filterQuery(userName, f)
+	var p storage.Page
+	err := scanPage(s.pool.QueryRow(ctx, "SELECT "+selectColumns+" FROM links WHERE "+where+" ORDER BY created_time LIMIT 1", args...), &p)
 	if err == pgx.ErrNoRows {
 		return &storage.Page{}, storage.NewNoResultError()
 	} else if err != nil {
@@ -71,42 +187,56 @@ func (s *DBStorage) Remove(ctx context.Context, p *storage.Page) error {
 	if err != nil {
 		return err
 	}
+	// the blob stays in the store, other users may have the same snapshot. Unused blobs are collected later.
+	_, err = s.pool.Exec(ctx, "DELETE FROM "+snapshotsTable+" WHERE url = $1 AND user_name = $2", p.URL, p.UserName)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func (s *DBStorage) PickAll(ctx context.Context, userName string) ([]storage.Page, error) {
-	pages := make([]storage.Page, 0, 20)
+// Archive keeps the page and its snapshot but takes it out of the lists
+func (s *DBStorage) Archive(ctx context.Context, p *storage.Page) error {
+	_, err := s.pool.Exec(ctx, "UPDATE links SET archived = true WHERE url = $1 AND user_name = $2", p.URL, p.UserName)
+	if err != nil {
+		return fmt.Errorf("can't archive page: %w", err)
+	}
+	return nil
+}
 
-	rows, err := s.pool.Query(ctx, "SELECT url, user_name, tags, created_time FROM links WHERE user_name = $1 ORDER BY created_time", userName)
-	defer rows.Close()
+func (s *DBStorage) PickAll(ctx context.Context, userName string) ([]storage.Page, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE user_name = $1 ORDER BY created_time", userName)
 	if err != nil {
 		return nil, fmt.Errorf("can't pick all rows: %w", err)
 	}
 
-	for rows.Next() {
-		var p storage.Page
-		err = rows.Scan(&p.URL, &p.UserName, &p.Tags, &p.Created)
-		if err != nil {
-			return nil, fmt.Errorf("can't scan row: %w", err)
-		}
-		pages = append(pages, p)
+	return scanPages(rows)
+}
+
+// Select returns the user's pages matching the filter
+func (s *DBStorage) Select(ctx context.Context, userName string, f storage.Filter) ([]storage.Page, error) {
+	where, args := filterQuery(userName, f)
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE "+where+" ORDER BY created_time", args...)
+	if err != nil {
+		return nil, fmt.Errorf("can't select rows: %w", err)
 	}
 
-	return pages, nil
+	return scanPages(rows)
 }
 
-func (s *DBStorage) SelectTags(ctx context.Context, userName string) ([]string, error) {
-	tags := make([]string, 0, 10)
+func (s *DBStorage) SelectTags(ctx context.Context, userName string) ([]storage.Tag, error) {
+	tags := make([]storage.Tag, 0, 10)
 
-	rows, err := s.pool.Query(ctx, "SELECT DISTINCT tags FROM links WHERE user_name = $1 AND tags != '' ORDER BY tags", userName)
+	rows, err := s.pool.Query(ctx, "SELECT tags, MIN(id) FROM links WHERE user_name = $1 AND tags != '' AND NOT archived "+
+		"GROUP BY tags ORDER BY tags", userName)
 	defer rows.Close()
 	if err != nil {
 		return nil, fmt.Errorf("can't select tags: %w", err)
 	}
 
 	for rows.Next() {
-		var t string
-		err = rows.Scan(&t)
+		var t storage.Tag
+		err = rows.Scan(&t.Name, &t.PageID)
 		if err != nil {
 			return nil, fmt.Errorf("can't scan row: %w", err)
 		}
@@ -117,7 +247,7 @@ func (s *DBStorage) SelectTags(ctx context.Context, userName string) ([]string,
 }
 
 func (s *DBStorage) SelectByTag(ctx context.Context, tag string, userName string) ([]string, error) {
-	rows, err := s.pool.Query(ctx, "SELECT url FROM links WHERE user_name = $1 AND tags = $2 ORDER BY created_time", userName, tag)
+	rows, err := s.pool.Query(ctx, "SELECT url FROM links WHERE user_name = $1 AND tags = $2 AND NOT archived ORDER BY created_time", userName, tag)
 	defer rows.Close()
 	if err != nil {
 		return nil, fmt.Errorf("can't select rows: %w", err)
@@ -136,10 +266,55 @@ func (s *DBStorage) SelectByTag(ctx context.Context, tag string, userName string
 	return urls, nil
 }
 
+func (s *DBStorage) SelectFailed(ctx context.Context, userName string) ([]storage.Page, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE user_name = $1 AND status = $2 ORDER BY created_time",
+		userName, storage.StatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("can't select failed rows: %w", err)
+	}
+
+	return scanPages(rows)
+}
+
+// SelectOutdated returns pages tagged by the classifier other than the given one
+func (s *DBStorage) SelectOutdated(ctx context.Context, classifier string, modelVersion string, limit int) ([]storage.Page, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE tag_source = $1 AND status = $2 "+
+		"AND (classifier != $3 OR model_version != $4) ORDER BY created_time LIMIT $5",
+		storage.TagSourceML, storage.StatusTagged, classifier, modelVersion, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't select outdated rows: %w", err)
+	}
+
+	return scanPages(rows)
+}
+
 func (s *DBStorage) BatchUpdate(ctx context.Context, pages []storage.Page) error {
+	return s.updatePages(ctx, pages, "UPDATE links SET tags = $1, status = $2, status_reason = $3, tag_source = $4, "+
+		"classifier = $5, model_version = $6, ")
+}
+
+// UpdateTagged saves the results of the tag worker. The page may have got a manual tag while it waited
+// in the queue, such tags and their status are kept and only the fetched content is saved.
+func (s *DBStorage) UpdateTagged(ctx context.Context, pages []storage.Page) error {
+	return s.updatePages(ctx, pages, "UPDATE links SET "+
+		"tags = CASE WHEN tag_source = 'manual' THEN tags ELSE $1 END, "+
+		"status = CASE WHEN tag_source = 'manual' THEN status ELSE $2 END, "+
+		"status_reason = CASE WHEN tag_source = 'manual' THEN status_reason ELSE $3 END, "+
+		"tag_source = CASE WHEN tag_source = 'manual' THEN tag_source ELSE $4 END, "+
+		"classifier = CASE WHEN tag_source = 'manual' THEN classifier ELSE $5 END, "+
+		"model_version = CASE WHEN tag_source = 'manual' THEN model_version ELSE $6 END, ")
+}
+
+// updatePages runs the update setting the tag columns for each page, the query sets the content columns
+func (s *DBStorage) updatePages(ctx context.Context, pages []storage.Page, setTags string) error {
 	b := &pgx.Batch{}
 	for _, v := range pages {
-		b.Queue("UPDATE links SET tags = $1 WHERE url = $2 AND user_name = $3", v.Tags, v.URL, v.UserName)
+		b.Queue(setTags+"content_type = $7, schema_type = $8, headline = $9, author = $10, published_time = $11, "+
+			"keywords = $12, article_section = $13, language = $14, word_count = $15, reading_seconds = $16, title = $17 "+
+			"WHERE url = $18 AND user_name = $19",
+			v.Tags, v.Status, v.StatusReason, v.TagSource, v.Classifier, v.ModelVersion, v.ContentType,
+			v.Metadata.Type, v.Metadata.Headline, v.Metadata.Author, v.Metadata.Published, v.Metadata.Keywords,
+			v.Metadata.Section, v.Language, v.WordCount, seconds(v.ReadingTime), v.Title, v.URL, v.UserName)
 	}
 	con, err := s.pool.Acquire(ctx)
 	if err != nil {
@@ -147,9 +322,377 @@ func (s *DBStorage) BatchUpdate(ctx context.Context, pages []storage.Page) error
 	}
 	defer con.Release()
 	res := con.SendBatch(ctx, b)
-	_, err = res.Exec()
+	defer res.Close()
+	for range pages {
+		if _, err = res.Exec(); err != nil {
+			return fmt.Errorf("error exec batch: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *DBStorage) GetByID(ctx context.Context, ID int, userName string) (*storage.Page, error) {
+	var p storage.Page
+	err := scanPage(s.pool.QueryRow(ctx, "SELECT "+selectColumns+" FROM links WHERE id = $1 AND user_name = $2", ID, userName), &p)
+	if err == pgx.ErrNoRows {
+		return nil, storage.NewNoResultError()
+	} else if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SelectUnchecked returns pages of all users whose links were last checked before the given time,
+// the longest unchecked first
+func (s *DBStorage) SelectUnchecked(ctx context.Context, checkedBefore time.Time, limit int) ([]storage.Page, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE checked_time < $1 ORDER BY checked_time LIMIT $2",
+		checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't select unchecked rows: %w", err)
+	}
+
+	return scanPages(rows)
+}
+
+func (s *DBStorage) SelectBroken(ctx context.Context, userName string) ([]storage.Page, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE user_name = $1 AND link_status IN ($2, $3) ORDER BY created_time",
+		userName, storage.LinkDead, storage.LinkParked)
+	if err != nil {
+		return nil, fmt.Errorf("can't select broken rows: %w", err)
+	}
+
+	return scanPages(rows)
+}
+
+// SaveChecks updates link check results, tags are left as they are
+func (s *DBStorage) SaveChecks(ctx context.Context, pages []storage.Page) error {
+	b := &pgx.Batch{}
+	for _, v := range pages {
+		b.Queue("UPDATE links SET link_status = $1, http_status = $2, final_url = $3, last_alive_time = $4, "+
+			"checked_time = $5 WHERE url = $6 AND user_name = $7",
+			v.LinkStatus, v.HTTPStatus, v.FinalURL, v.LastAlive, v.Checked, v.URL, v.UserName)
+	}
+	con, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer con.Release()
+	res := con.SendBatch(ctx, b)
+	defer res.Close()
+	for range pages {
+		if _, err = res.Exec(); err != nil {
+			return fmt.Errorf("error exec batch: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *DBStorage) GetContent(ctx context.Context, URL string) (*storage.Content, error) {
+	var c storage.Content
+	var readingSeconds int
+	err := s.pool.QueryRow(ctx, "SELECT "+contentColumns+" FROM "+contentsTable+" WHERE url = $1", URL).Scan(
+		&c.URL, &c.Title, &c.Byline, &c.Body, &c.ContentType, &c.Tags, &c.TagSource, &c.Classifier,
+		&c.ModelVersion, &c.ETag, &c.LastModified, &c.Fetched, &c.Expires, &c.SnapshotKey, &c.SnapshotSize,
+		&c.Metadata.Type, &c.Metadata.Headline, &c.Metadata.Author, &c.Metadata.Published, &c.Metadata.Keywords,
+		&c.Metadata.Section, &c.Language, &c.WordCount, &readingSeconds)
+	if err == pgx.ErrNoRows {
+		return nil, storage.NewNoResultError()
+	} else if err != nil {
+		return nil, fmt.Errorf("can't get content: %w", err)
+	}
+	c.ReadingTime = time.Duration(readingSeconds) * time.Second
+	return &c, nil
+}
+
+// SaveContent inserts the content or replaces the saved one
+func (s *DBStorage) SaveContent(ctx context.Context, c *storage.Content) error {
+	_, err := s.pool.Exec(ctx, "INSERT INTO "+contentsTable+" ("+contentColumns+") "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24) "+
+		"ON CONFLICT (url) DO UPDATE SET "+
+		"title = $2, byline = $3, body = $4, content_type = $5, tags = $6, tag_source = $7, classifier = $8, "+
+		"model_version = $9, etag = $10, last_modified = $11, fetched_time = $12, expires_time = $13, "+
+		"snapshot_key = $14, snapshot_size = $15, schema_type = $16, headline = $17, author = $18, "+
+		"published_time = $19, keywords = $20, article_section = $21, language = $22, word_count = $23, "+
+		"reading_seconds = $24",
+		c.URL, c.Title, c.Byline, c.Body, c.ContentType, c.Tags, c.TagSource, c.Classifier,
+		c.ModelVersion, c.ETag, c.LastModified, c.Fetched, c.Expires, c.SnapshotKey, c.SnapshotSize,
+		c.Metadata.Type, c.Metadata.Headline, c.Metadata.Author, c.Metadata.Published, c.Metadata.Keywords,
+		c.Metadata.Section, c.Language, c.WordCount, seconds(c.ReadingTime))
+	if err != nil {
+		return fmt.Errorf("can't save content: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot inserts the user's snapshot or replaces the saved one if the user's snapshots
+// stay within the quota, false is returned when they don't
+func (s *DBStorage) SaveSnapshot(ctx context.Context, sn *storage.Snapshot, quota int64) (bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("can't begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// snapshots of the user are saved one at a time, so two of them can't both take the space left
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", sn.UserName); err != nil {
+		return false, fmt.Errorf("can't lock snapshots: %w", err)
+	}
+
+	var used int64
+	err = tx.QueryRow(ctx, "SELECT COALESCE(SUM(size), 0) FROM "+snapshotsTable+" WHERE user_name = $1 AND url != $2",
+		sn.UserName, sn.URL).Scan(&used)
+	if err != nil {
+		return false, fmt.Errorf("can't count snapshots size: %w", err)
+	}
+	if used+sn.Size > quota {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO "+snapshotsTable+" (url, user_name, blob_key, size, created_time) "+
+		"VALUES ($1, $2, $3, $4, $5) ON CONFLICT (url, user_name) DO UPDATE SET blob_key = $3, size = $4, created_time = $5",
+		sn.URL, sn.UserName, sn.Key, sn.Size, sn.Created)
 	if err != nil {
-		return fmt.Errorf("error exec batch: %w", err)
+		return false, fmt.Errorf("can't save snapshot: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("can't save snapshot: %w", err)
+	}
+	return true, nil
+}
+
+func (s *DBStorage) GetSnapshot(ctx context.Context, URL string, userName string) (*storage.Snapshot, error) {
+	var sn storage.Snapshot
+	err := s.pool.QueryRow(ctx, "SELECT url, user_name, blob_key, size, created_time FROM "+snapshotsTable+
+		" WHERE url = $1 AND user_name = $2", URL, userName).Scan(&sn.URL, &sn.UserName, &sn.Key, &sn.Size, &sn.Created)
+	if err == pgx.ErrNoRows {
+		return nil, storage.NewNoResultError()
+	} else if err != nil {
+		return nil, fmt.Errorf("can't get snapshot: %w", err)
+	}
+	return &sn, nil
+}
+
+// UnusedSnapshotKeys returns the keys no user's snapshot and no cached content points to
+func (s *DBStorage) UnusedSnapshotKeys(ctx context.Context, keys []string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, "SELECT k FROM unnest($1::varchar[]) AS k "+
+		"WHERE NOT EXISTS (SELECT 1 FROM "+snapshotsTable+" WHERE blob_key = k) "+
+		"AND NOT EXISTS (SELECT 1 FROM "+contentsTable+" WHERE snapshot_key = k)", keys)
+	if err != nil {
+		return nil, fmt.Errorf("can't select unused snapshot keys: %w", err)
+	}
+	defer rows.Close()
+
+	unused := make([]string, 0, len(keys))
+	for rows.Next() {
+		var k string
+		if err = rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("can't scan snapshot key: %w", err)
+		}
+		unused = append(unused, k)
+	}
+	return unused, rows.Err()
+}
+
+func (s *DBStorage) GetSettings(ctx context.Context, userName string) (*storage.Settings, error) {
+	var st storage.Settings
+	err := s.pool.QueryRow(ctx, "SELECT "+settingsColumns+" FROM "+settingsTable+" WHERE user_name = $1", userName).
+		Scan(&st.UserName, &st.ChatID, &st.Language, &st.PageSize, &st.ArchiveOnGet, &st.LinkPreview, &st.AutoTag,
+			&st.Digest, &st.DigestRandom, &st.DigestHour, &st.Timezone, &st.ReplyLanguage)
+	if err == pgx.ErrNoRows {
+		return nil, storage.NewNoResultError()
+	} else if err != nil {
+		return nil, fmt.Errorf("can't get settings: %w", err)
+	}
+	return &st, nil
+}
+
+func (s *DBStorage) SaveSettings(ctx context.Context, st *storage.Settings) error {
+	_, err := s.pool.Exec(ctx, "INSERT INTO "+settingsTable+" ("+settingsColumns+") "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) "+
+		"ON CONFLICT (user_name) DO UPDATE SET chat_id = $2, language = $3, page_size = $4, archive_on_get = $5, "+
+		"link_preview = $6, auto_tag = $7, digest = $8, digest_random = $9, digest_hour = $10, timezone = $11, "+
+		"reply_language = $12",
+		st.UserName, st.ChatID, st.Language, st.PageSize, st.ArchiveOnGet, st.LinkPreview, st.AutoTag,
+		st.Digest, st.DigestRandom, st.DigestHour, st.Timezone, st.ReplyLanguage)
+	if err != nil {
+		return fmt.Errorf("can't save settings: %w", err)
+	}
+	return nil
+}
+
+// SelectDigests returns the settings of users who get a digest and have a chat to get it in
+func (s *DBStorage) SelectDigests(ctx context.Context) ([]storage.Settings, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+settingsColumns+" FROM "+settingsTable+" WHERE digest != '' AND chat_id != 0")
+	if err != nil {
+		return nil, fmt.Errorf("can't select digests: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]storage.Settings, 0, 20)
+	for rows.Next() {
+		var st storage.Settings
+		err = rows.Scan(&st.UserName, &st.ChatID, &st.Language, &st.PageSize, &st.ArchiveOnGet, &st.LinkPreview,
+			&st.AutoTag, &st.Digest, &st.DigestRandom, &st.DigestHour, &st.Timezone, &st.ReplyLanguage)
+		if err != nil {
+			return nil, fmt.Errorf("can't scan row: %w", err)
+		}
+		res = append(res, st)
+	}
+
+	return res, rows.Err()
+}
+
+// ClaimDigest marks the digest of the slot as sent and reports whether this call did it,
+// so a digest is sent once however many bots race for it
+func (s *DBStorage) ClaimDigest(ctx context.Context, userName string, slot time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx, "UPDATE "+settingsTable+" SET digest_sent = $2 WHERE user_name = $1 AND digest_sent < $2",
+		userName, slot)
+	if err != nil {
+		return false, fmt.Errorf("can't claim digest: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// SelectUnread returns the oldest or random pages in the lists that aren't snoozed
+func (s *DBStorage) SelectUnread(ctx context.Context, userName string, now time.Time, limit int, random bool) ([]storage.Page, error) {
+	order := "created_time"
+	if random {
+		order = "random()"
+	}
+	rows, err := s.pool.Query(ctx, "SELECT "+selectColumns+" FROM links WHERE user_name = $1 AND NOT archived "+
+		"AND snoozed_until <= $2 ORDER BY "+order+" LIMIT $3", userName, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't select unread pages: %w", err)
+	}
+
+	return scanPages(rows)
+}
+
+// Snooze keeps the page out of digests until the given time
+func (s *DBStorage) Snooze(ctx context.Context, p *storage.Page, until time.Time) error {
+	_, err := s.pool.Exec(ctx, "UPDATE links SET snoozed_until = $3 WHERE url = $1 AND user_name = $2",
+		p.URL, p.UserName, until)
+	if err != nil {
+		return fmt.Errorf("can't snooze page: %w", err)
+	}
+	return nil
+}
+
+func (s *DBStorage) SaveReminder(ctx context.Context, r *storage.Reminder) error {
+	err := s.pool.QueryRow(ctx, "INSERT INTO "+remindersTable+" (user_name, chat_id, url, due_time, created_time, language) "+
+		"VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", r.UserName, r.ChatID, r.URL, r.Due, r.Created, r.Language).Scan(&r.ID)
+	if err != nil {
+		return fmt.Errorf("can't save reminder: %w", err)
+	}
+	return nil
+}
+
+// ClaimReminders marks reminders due by now as sent and returns them. Rows locked by another bot
+// are skipped, so each reminder goes to one of them.
+func (s *DBStorage) ClaimReminders(ctx context.Context, now time.Time, limit int) ([]storage.Reminder, error) {
+	rows, err := s.pool.Query(ctx, "UPDATE "+remindersTable+" SET sent = true WHERE id IN "+
+		"(SELECT id FROM "+remindersTable+" WHERE NOT sent AND due_time <= $1 ORDER BY due_time LIMIT $2 FOR UPDATE SKIP LOCKED) "+
+		"RETURNING "+reminderColumns, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't claim reminders: %w", err)
+	}
+	defer rows.Close()
+
+	res := make([]storage.Reminder, 0, limit)
+	for rows.Next() {
+		var r storage.Reminder
+		if err = rows.Scan(&r.ID, &r.UserName, &r.ChatID, &r.URL, &r.Due, &r.Created, &r.Language); err != nil {
+			return nil, fmt.Errorf("can't scan row: %w", err)
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+// SnoozeReminder makes the user's reminder due again at r.Due and fills the rest of r
+func (s *DBStorage) SnoozeReminder(ctx context.Context, r *storage.Reminder) error {
+	err := s.pool.QueryRow(ctx, "UPDATE "+remindersTable+" SET due_time = $3, sent = false "+
+		"WHERE id = $1 AND user_name = $2 RETURNING "+reminderColumns, r.ID, r.UserName, r.Due).
+		Scan(&r.ID, &r.UserName, &r.ChatID, &r.URL, &r.Due, &r.Created, &r.Language)
+	if err == pgx.ErrNoRows {
+		return storage.NewNoResultError()
+	} else if err != nil {
+		return fmt.Errorf("can't snooze reminder: %w", err)
+	}
+	return nil
+}
+
+func (s *DBStorage) RemoveReminder(ctx context.Context, r *storage.Reminder) error {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM "+remindersTable+" WHERE id = $1 AND user_name = $2", r.ID, r.UserName)
+	if err != nil {
+		return fmt.Errorf("can't remove reminder: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.NewNoResultError()
+	}
+	return nil
+}
+
+func scanPage(row pgx.Row, p *storage.Page) error {
+	var readingSeconds int
+	err := row.Scan(&p.ID, &p.URL, &p.UserName, &p.Tags, &p.Created, &p.Status, &p.StatusReason,
+		&p.TagSource, &p.Classifier, &p.ModelVersion, &p.ContentType, &p.Title, &p.Archived, &p.SnoozedUntil,
+		&p.LinkStatus, &p.HTTPStatus, &p.FinalURL, &p.LastAlive, &p.Checked,
+		&p.Metadata.Type, &p.Metadata.Headline, &p.Metadata.Author, &p.Metadata.Published, &p.Metadata.Keywords,
+		&p.Metadata.Section, &p.Language, &p.WordCount, &readingSeconds)
+	p.ReadingTime = time.Duration(readingSeconds) * time.Second
+	return err
+}
+
+func scanPages(rows pgx.Rows) ([]storage.Page, error) {
+	defer rows.Close()
+
+	pages := make([]storage.Page, 0, 20)
+	for rows.Next() {
+		var p storage.Page
+		if err := scanPage(rows, &p); err != nil {
+			return nil, fmt.Errorf("can't scan row: %w", err)
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, rows.Err()
+}
+
+// filterQuery builds the condition of the user's pages matching the filter.
+// Pages of unknown length are left out when the reading time is limited.
+func filterQuery(userName string, f storage.Filter) (string, []any) {
+	where := "user_name = $1 AND NOT archived"
+	args := []any{userName}
+	if f.SchemaType != "" {
+		args = append(args, f.SchemaType)
+		where += fmt.Sprintf(" AND lower(schema_type) = lower($%d)", len(args))
+	}
+	if f.Language != "" {
+		args = append(args, f.Language)
+		where += fmt.Sprintf(" AND language = lower($%d)", len(args))
+	}
+	if f.MinReadingTime > 0 {
+		args = append(args, seconds(f.MinReadingTime))
+		where += fmt.Sprintf(" AND reading_seconds >= $%d", len(args))
+	}
+	if f.MaxReadingTime > 0 {
+		args = append(args, seconds(f.MaxReadingTime))
+		where += fmt.Sprintf(" AND reading_seconds > 0 AND reading_seconds <= $%d", len(args))
+	}
+	return where, args
+}
+
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
+
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, m := range migrations {
+		if _, err := pool.Exec(ctx, m); err != nil {
+			return fmt.Errorf("can't apply migration %q: %w", m, err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,522 @@
+// Package memory keeps pages in memory. It is the storage of tests and behaves like the database one.
+package memory
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"url-saver-bot/internal/storage"
+)
+
+type MemoryStorage struct {
+	mu        sync.Mutex
+	nextID    int
+	pages     map[int]*storage.Page
+	contents  map[string]storage.Content
+	snapshots map[snapshotKey]storage.Snapshot
+	settings  map[string]storage.Settings
+	// digestSent is the slot of the last digest of each user
+	digestSent map[string]time.Time
+	reminders  map[int]*reminder
+}
+
+// reminder is kept with the flag the database has
+type reminder struct {
+	storage.Reminder
+	sent bool
+}
+
+var _ storage.Storage = (*MemoryStorage)(nil)
+
+type snapshotKey struct {
+	url      string
+	userName string
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		nextID:     1,
+		pages:      make(map[int]*storage.Page),
+		contents:   make(map[string]storage.Content),
+		snapshots:  make(map[snapshotKey]storage.Snapshot),
+		settings:   make(map[string]storage.Settings),
+		digestSent: make(map[string]time.Time),
+		reminders:  make(map[int]*reminder),
+	}
+}
+
+func (s *MemoryStorage) Save(ctx context.Context, p *storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if page := s.find(p.URL, p.UserName); page != nil && page.Archived {
+		page.Archived = false
+		page.Created = p.Created
+		return nil
+	} else if page != nil {
+		return storage.NewAlreadyExistsError()
+	}
+	page := *p
+	page.ID = s.nextID
+	s.nextID++
+	s.pages[page.ID] = &page
+	return nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, URL string, userName string) (*storage.Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.find(URL, userName)
+	if p == nil {
+		return nil, storage.NewNoResultError()
+	}
+	page := *p
+	return &page, nil
+}
+
+// Pick returns the user's first saved page matching the filter
+func (s *MemoryStorage) Pick(ctx context.Context, userName string, f storage.Filter) (*storage.Page, error) {
+	pages := s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && matches(p, f)
+	})
+	if len(pages) == 0 {
+		return nil, storage.NewNoResultError()
+	}
+	return &pages[0], nil
+}
+
+func (s *MemoryStorage) Remove(ctx context.Context, p *storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if page := s.find(p.URL, p.UserName); page != nil {
+		delete(s.pages, page.ID)
+	}
+	delete(s.snapshots, snapshotKey{url: p.URL, userName: p.UserName})
+	return nil
+}
+
+// Archive keeps the page and its snapshot but takes it out of the lists
+func (s *MemoryStorage) Archive(ctx context.Context, p *storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if page := s.find(p.URL, p.UserName); page != nil {
+		page.Archived = true
+	}
+	return nil
+}
+
+func (s *MemoryStorage) PickAll(ctx context.Context, userName string) ([]storage.Page, error) {
+	return s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName
+	}), nil
+}
+
+// Select returns the user's pages matching the filter
+func (s *MemoryStorage) Select(ctx context.Context, userName string, f storage.Filter) ([]storage.Page, error) {
+	return s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && matches(p, f)
+	}), nil
+}
+
+func (s *MemoryStorage) SelectTags(ctx context.Context, userName string) ([]storage.Tag, error) {
+	seen := make(map[string]bool)
+	tags := make([]storage.Tag, 0, 10)
+	for _, p := range s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && p.Tags != "" && !p.Archived
+	}) {
+		if !seen[p.Tags] {
+			seen[p.Tags] = true
+			tags = append(tags, storage.Tag{Name: p.Tags, PageID: p.ID})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *MemoryStorage) SelectByTag(ctx context.Context, tag string, userName string) ([]string, error) {
+	urls := make([]string, 0, 10)
+	for _, p := range s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && p.Tags == tag && !p.Archived
+	}) {
+		urls = append(urls, p.URL)
+	}
+	return urls, nil
+}
+
+func (s *MemoryStorage) SelectFailed(ctx context.Context, userName string) ([]storage.Page, error) {
+	return s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && p.Status == storage.StatusFailed
+	}), nil
+}
+
+// SelectOutdated returns pages tagged by the classifier other than the given one
+func (s *MemoryStorage) SelectOutdated(ctx context.Context, classifier string, modelVersion string, limit int) ([]storage.Page, error) {
+	pages := s.selectPages(func(p *storage.Page) bool {
+		return p.TagSource == storage.TagSourceML && p.Status == storage.StatusTagged &&
+			(p.Classifier != classifier || p.ModelVersion != modelVersion)
+	})
+	return limitPages(pages, limit), nil
+}
+
+func (s *MemoryStorage) BatchUpdate(ctx context.Context, pages []storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range pages {
+		p := s.find(v.URL, v.UserName)
+		if p == nil {
+			continue
+		}
+		setTags(p, v)
+		setContent(p, v)
+	}
+	return nil
+}
+
+// UpdateTagged saves the results of the tag worker, manual tags set meanwhile are kept
+func (s *MemoryStorage) UpdateTagged(ctx context.Context, pages []storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range pages {
+		p := s.find(v.URL, v.UserName)
+		if p == nil {
+			continue
+		}
+		if p.TagSource != storage.TagSourceManual {
+			setTags(p, v)
+		}
+		setContent(p, v)
+	}
+	return nil
+}
+
+func setTags(p *storage.Page, v storage.Page) {
+	p.Tags = v.Tags
+	p.Status = v.Status
+	p.StatusReason = v.StatusReason
+	p.TagSource = v.TagSource
+	p.Classifier = v.Classifier
+	p.ModelVersion = v.ModelVersion
+}
+
+func setContent(p *storage.Page, v storage.Page) {
+	p.ContentType = v.ContentType
+	p.Title = v.Title
+	p.Metadata = v.Metadata
+	p.Language = v.Language
+	p.WordCount = v.WordCount
+	p.ReadingTime = v.ReadingTime
+}
+
+func (s *MemoryStorage) GetContent(ctx context.Context, URL string) (*storage.Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.contents[URL]
+	if !ok {
+		return nil, storage.NewNoResultError()
+	}
+	return &c, nil
+}
+
+// SaveContent inserts the content or replaces the saved one
+func (s *MemoryStorage) SaveContent(ctx context.Context, c *storage.Content) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contents[c.URL] = *c
+	return nil
+}
+
+// SaveSnapshot inserts the user's snapshot or replaces the saved one if the user's snapshots
+// stay within the quota, false is returned when they don't
+func (s *MemoryStorage) SaveSnapshot(ctx context.Context, sn *storage.Snapshot, quota int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := snapshotKey{url: sn.URL, userName: sn.UserName}
+	used := sn.Size
+	for k, v := range s.snapshots {
+		if k.userName == sn.UserName && k != key {
+			used += v.Size
+		}
+	}
+	if used > quota {
+		return false, nil
+	}
+
+	s.snapshots[key] = *sn
+	return true, nil
+}
+
+func (s *MemoryStorage) GetSnapshot(ctx context.Context, URL string, userName string) (*storage.Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sn, ok := s.snapshots[snapshotKey{url: URL, userName: userName}]
+	if !ok {
+		return nil, storage.NewNoResultError()
+	}
+	return &sn, nil
+}
+
+// UnusedSnapshotKeys returns the keys no user's snapshot and no cached content points to
+func (s *MemoryStorage) UnusedSnapshotKeys(ctx context.Context, keys []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make(map[string]bool, len(s.snapshots)+len(s.contents))
+	for _, sn := range s.snapshots {
+		used[sn.Key] = true
+	}
+	for _, c := range s.contents {
+		used[c.SnapshotKey] = true
+	}
+
+	unused := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !used[k] {
+			unused = append(unused, k)
+		}
+	}
+	return unused, nil
+}
+
+func (s *MemoryStorage) GetByID(ctx context.Context, ID int, userName string) (*storage.Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pages[ID]
+	if !ok || p.UserName != userName {
+		return nil, storage.NewNoResultError()
+	}
+	page := *p
+	return &page, nil
+}
+
+// SelectUnchecked returns pages of all users whose links were last checked before the given time,
+// the longest unchecked first
+func (s *MemoryStorage) SelectUnchecked(ctx context.Context, checkedBefore time.Time, limit int) ([]storage.Page, error) {
+	pages := s.selectPages(func(p *storage.Page) bool {
+		return p.Checked.Before(checkedBefore)
+	})
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Checked.Before(pages[j].Checked)
+	})
+	return limitPages(pages, limit), nil
+}
+
+func (s *MemoryStorage) SelectBroken(ctx context.Context, userName string) ([]storage.Page, error) {
+	return s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && (p.LinkStatus == storage.LinkDead || p.LinkStatus == storage.LinkParked)
+	}), nil
+}
+
+// SaveChecks updates link check results, tags are left as they are
+func (s *MemoryStorage) SaveChecks(ctx context.Context, pages []storage.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range pages {
+		p := s.find(v.URL, v.UserName)
+		if p == nil {
+			continue
+		}
+		p.LinkStatus = v.LinkStatus
+		p.HTTPStatus = v.HTTPStatus
+		p.FinalURL = v.FinalURL
+		p.LastAlive = v.LastAlive
+		p.Checked = v.Checked
+	}
+	return nil
+}
+
+// find returns the stored page, s.mu must be held
+func (s *MemoryStorage) find(URL string, userName string) *storage.Page {
+	for _, p := range s.pages {
+		if p.URL == URL && p.UserName == userName {
+			return p
+		}
+	}
+	return nil
+}
+
+// selectPages returns copies of matching pages in the order they were saved
+func (s *MemoryStorage) selectPages(match func(p *storage.Page) bool) []storage.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pages := make([]storage.Page, 0, 20)
+	for _, p := range s.pages {
+		if match(p) {
+			pages = append(pages, *p)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if !pages[i].Created.Equal(pages[j].Created) {
+			return pages[i].Created.Before(pages[j].Created)
+		}
+		return pages[i].ID < pages[j].ID
+	})
+	return pages
+}
+
+// matches applies the filter the way the database query does
+func matches(p *storage.Page, f storage.Filter) bool {
+	if p.Archived {
+		return false
+	}
+	if f.SchemaType != "" && !strings.EqualFold(p.Metadata.Type, f.SchemaType) {
+		return false
+	}
+	if f.Language != "" && p.Language != strings.ToLower(f.Language) {
+		return false
+	}
+	if f.MinReadingTime > 0 && p.ReadingTime < f.MinReadingTime {
+		return false
+	}
+	if f.MaxReadingTime > 0 && (p.ReadingTime <= 0 || p.ReadingTime > f.MaxReadingTime) {
+		return false
+	}
+	return true
+}
+
+func limitPages(pages []storage.Page, limit int) []storage.Page {
+	if len(pages) > limit {
+		return pages[:limit]
+	}
+	return pages
+}
+
+func (s *MemoryStorage) GetSettings(ctx context.Context, userName string) (*storage.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.settings[userName]
+	if !ok {
+		return nil, storage.NewNoResultError()
+	}
+	return &st, nil
+}
+
+func (s *MemoryStorage) SaveSettings(ctx context.Context, st *storage.Settings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settings[st.UserName] = *st
+	return nil
+}
+
+func (s *MemoryStorage) SelectDigests(ctx context.Context) ([]storage.Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]storage.Settings, 0, len(s.settings))
+	for _, st := range s.settings {
+		if st.Digest != storage.DigestOff && st.ChatID != 0 {
+			res = append(res, st)
+		}
+	}
+	return res, nil
+}
+
+func (s *MemoryStorage) ClaimDigest(ctx context.Context, userName string, slot time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.settings[userName]; !ok || !s.digestSent[userName].Before(slot) {
+		return false, nil
+	}
+	s.digestSent[userName] = slot
+	return true, nil
+}
+
+// SelectUnread returns the oldest pages in the lists that aren't snoozed, random ones are shuffled
+func (s *MemoryStorage) SelectUnread(ctx context.Context, userName string, now time.Time, limit int, random bool) ([]storage.Page, error) {
+	pages := s.selectPages(func(p *storage.Page) bool {
+		return p.UserName == userName && !p.Archived && !p.SnoozedUntil.After(now)
+	})
+	if random {
+		rand.Shuffle(len(pages), func(i, j int) {
+			pages[i], pages[j] = pages[j], pages[i]
+		})
+	}
+	return limitPages(pages, limit), nil
+}
+
+func (s *MemoryStorage) Snooze(ctx context.Context, p *storage.Page, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if page := s.find(p.URL, p.UserName); page != nil {
+		page.SnoozedUntil = until
+	}
+	return nil
+}
+
+func (s *MemoryStorage) SaveReminder(ctx context.Context, r *storage.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// reminders share the counter of pages, ids only need to be unique
+	r.ID = s.nextID
+	s.nextID++
+	s.reminders[r.ID] = &reminder{Reminder: *r}
+	return nil
+}
+
+// ClaimReminders marks reminders due by now as sent and returns them, the earliest first
+func (s *MemoryStorage) ClaimReminders(ctx context.Context, now time.Time, limit int) ([]storage.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]storage.Reminder, 0, limit)
+	for _, r := range s.reminders {
+		if !r.sent && !r.Due.After(now) {
+			res = append(res, r.Reminder)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Due.Before(res[j].Due)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	for _, r := range res {
+		s.reminders[r.ID].sent = true
+	}
+	return res, nil
+}
+
+func (s *MemoryStorage) SnoozeReminder(ctx context.Context, r *storage.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved, ok := s.reminders[r.ID]
+	if !ok || saved.UserName != r.UserName {
+		return storage.NewNoResultError()
+	}
+	saved.Due = r.Due
+	saved.sent = false
+	*r = saved.Reminder
+	return nil
+}
+
+func (s *MemoryStorage) RemoveReminder(ctx context.Context, r *storage.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved, ok := s.reminders[r.ID]
+	if !ok || saved.UserName != r.UserName {
+		return storage.NewNoResultError()
+	}
+	delete(s.reminders, r.ID)
+	return nil
+}
@@ -7,17 +7,239 @@ import (
 
 type Storage interface {
 	Save(ctx context.Context, p *Page) error
-	Pick(ctx context.Context, userName string) (*Page, error)
+	Get(ctx context.Context, URL string, userName string) (*Page, error)
+	Pick(ctx context.Context, userName string, f Filter) (*Page, error)
 	Remove(ctx context.Context, p *Page) error
 	PickAll(ctx context.Context, userName string) ([]Page, error)
-	SelectTags(ctx context.Context, userName string) ([]string, error)
+	Select(ctx context.Context, userName string, f Filter) ([]Page, error)
+	SelectTags(ctx context.Context, userName string) ([]Tag, error)
 	SelectByTag(ctx context.Context, tag string, userName string) ([]string, error)
+	SelectFailed(ctx context.Context, userName string) ([]Page, error)
+	SelectOutdated(ctx context.Context, classifier string, modelVersion string, limit int) ([]Page, error)
 	BatchUpdate(ctx context.Context, pages []Page) error
+	UpdateTagged(ctx context.Context, pages []Page) error
+	GetContent(ctx context.Context, URL string) (*Content, error)
+	SaveContent(ctx context.Context, c *Content) error
+	SaveSnapshot(ctx context.Context, s *Snapshot, quota int64) (bool, error)
+	GetSnapshot(ctx context.Context, URL string, userName string) (*Snapshot, error)
+	UnusedSnapshotKeys(ctx context.Context, keys []string) ([]string, error)
+	GetByID(ctx context.Context, ID int, userName string) (*Page, error)
+	SelectUnchecked(ctx context.Context, checkedBefore time.Time, limit int) ([]Page, error)
+	SelectBroken(ctx context.Context, userName string) ([]Page, error)
+	SaveChecks(ctx context.Context, pages []Page) error
+	Archive(ctx context.Context, p *Page) error
+	GetSettings(ctx context.Context, userName string) (*Settings, error)
+	SaveSettings(ctx context.Context, s *Settings) error
+	SelectDigests(ctx context.Context) ([]Settings, error)
+	ClaimDigest(ctx context.Context, userName string, slot time.Time) (bool, error)
+	SelectUnread(ctx context.Context, userName string, now time.Time, limit int, random bool) ([]Page, error)
+	Snooze(ctx context.Context, p *Page, until time.Time) error
+	SaveReminder(ctx context.Context, r *Reminder) error
+	ClaimReminders(ctx context.Context, now time.Time, limit int) ([]Reminder, error)
+	SnoozeReminder(ctx context.Context, r *Reminder) error
+	RemoveReminder(ctx context.Context, r *Reminder) error
+}
+
+// Status shows how far the page got through tagging
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusTagged  Status = "tagged"
+	StatusFailed  Status = "failed"
+	// StatusNotFetched is set when the page was saved but we refused to download it
+	StatusNotFetched Status = "not_fetched"
+)
+
+// TagSource shows who set the page tags
+type TagSource string
+
+const (
+	TagSourceML     TagSource = "ml"
+	TagSourceManual TagSource = "manual"
+	TagSourceParser TagSource = "parser"
+)
+
+// LinkStatus is the result of the last link check
+type LinkStatus string
+
+const (
+	LinkUnchecked LinkStatus = ""
+	LinkAlive     LinkStatus = "alive"
+	LinkDead      LinkStatus = "dead"
+	// LinkParked is set when the link redirects to a domain parking or sale page
+	LinkParked LinkStatus = "parked"
+	// LinkUnknown is set when the link can't be checked, e.g. robots.txt disallows it
+	LinkUnknown LinkStatus = "unknown"
+)
+
+// Tag is a tag of the user's pages, PageID is one of them so buttons can refer to the tag by it
+type Tag struct {
+	Name   string
+	PageID int
 }
 
 type Page struct {
+	ID           int
+	URL          string
+	Tags         string
+	UserName     string
+	Created      time.Time
+	Status       Status
+	StatusReason string
+	TagSource    TagSource
+	Classifier   string
+	ModelVersion string
+	ContentType  string
+	Title        string
+	Archived     bool
+	// SnoozedUntil keeps the page out of digests until then
+	SnoozedUntil time.Time
+	LinkStatus   LinkStatus
+	HTTPStatus   int
+	FinalURL     string
+	LastAlive    time.Time
+	Checked      time.Time
+	Metadata     Metadata
+	Language     string
+	WordCount    int
+	// ReadingTime is zero when the page isn't fetched yet
+	ReadingTime time.Duration
+}
+
+// Metadata is the schema.org structured data published by the page.
+// Type is the content type facet: Article, Recipe, Product, VideoObject or another schema.org type.
+type Metadata struct {
+	Type      string
+	Headline  string
+	Author    string
+	Published time.Time
+	Keywords  []string
+	Section   string
+}
+
+// Filter narrows the user's pages, empty fields match everything
+type Filter struct {
+	SchemaType     string
+	Language       string
+	MinReadingTime time.Duration
+	MaxReadingTime time.Duration
+}
+
+// Content is the fetched and classified page shared by all users who saved it.
+// URL is canonical, so different spellings of the page share one record.
+type Content struct {
+	URL          string
+	Title        string
+	Byline       string
+	Body         string
+	ContentType  string
+	Tags         string
+	TagSource    TagSource
+	Classifier   string
+	ModelVersion string
+	ETag         string
+	LastModified string
+	Fetched      time.Time
+	Expires      time.Time
+	SnapshotKey  string
+	SnapshotSize int64
+	Metadata     Metadata
+	Language     string
+	WordCount    int
+	ReadingTime  time.Duration
+}
+
+// Snapshot is the user's offline copy of the page. Key points to the compressed file
+// in the blob store, Size counts towards the user's quota.
+type Snapshot struct {
 	URL      string
-	Tags     string
 	UserName string
+	Key      string
+	Size     int64
 	Created  time.Time
 }
+
+// Reminder is a link the user wants to get back to at Due
+type Reminder struct {
+	ID       int
+	UserName string
+	ChatID   int
+	URL      string
+	Due      time.Time
+	Created  time.Time
+	// Language the reminder was set in, it is sent in it unless the user picked one with /lang
+	Language string
+}
+
+// Settings are the user's preferences, users who changed nothing have the ones of NewSettings
+type Settings struct {
+	UserName string
+	// ChatID is where the digest is sent, it is set when the user changes settings
+	ChatID int
+	// Language of the replies, empty means the language of the user's Telegram app
+	Language string
+	// PageSize is the number of links /show_all sends at once, zero sends all of them
+	PageSize int
+	// ArchiveOnGet keeps pages taken with /get out of the lists instead of removing them
+	ArchiveOnGet bool
+	LinkPreview  bool
+	AutoTag      bool
+	Digest       Digest
+	// DigestRandom picks random unread pages for the digest instead of the oldest ones
+	DigestRandom bool
+	// DigestHour is the local hour the digest is sent at
+	DigestHour int
+	// Timezone is the IANA name of the user's time zone, empty means UTC
+	Timezone string
+	// ReplyLanguage is the language the user was answered in when the settings were saved,
+	// the digest is sent in it when Language is empty
+	ReplyLanguage string
+}
+
+// Digest is how often the user gets links they haven't read yet
+type Digest string
+
+const (
+	DigestOff    Digest = ""
+	DigestDaily  Digest = "daily"
+	DigestWeekly Digest = "weekly"
+)
+
+func NewSettings(userName string) *Settings {
+	return &Settings{
+		UserName:   userName,
+		AutoTag:    true,
+		DigestHour: 9,
+	}
+}
+
+// DigestSlot is the last time at or before now the digest was due, weekly digests are due on Mondays.
+// It is zero when the digest is off.
+func (s *Settings) DigestSlot(now time.Time) time.Time {
+	if s.Digest != DigestDaily && s.Digest != DigestWeekly {
+		return time.Time{}
+	}
+
+	local := now.In(s.Location())
+	day := local.Day()
+	step := 1
+	if s.Digest == DigestWeekly {
+		day -= (int(local.Weekday()) + 6) % 7
+		step = 7
+	}
+	slot := time.Date(local.Year(), local.Month(), day, s.DigestHour, 0, 0, 0, local.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -step)
+	}
+	return slot
+}
+
+// Location is the user's time zone, unknown zones are UTC
+func (s *Settings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
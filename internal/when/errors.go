@@ -0,0 +1,31 @@
+package when
+
+import "fmt"
+
+type UnknownTimeError struct {
+	text string
+}
+
+func (e *UnknownTimeError) Error() string {
+	return e.text
+}
+
+func NewUnknownTimeError(text string) *UnknownTimeError {
+	return &UnknownTimeError{
+		text: fmt.Sprintf("unknown time %q", text),
+	}
+}
+
+type PastTimeError struct {
+	text string
+}
+
+func (e *PastTimeError) Error() string {
+	return e.text
+}
+
+func NewPastTimeError(t string) *PastTimeError {
+	return &PastTimeError{
+		text: fmt.Sprintf("time %q has passed", t),
+	}
+}
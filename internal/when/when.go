@@ -0,0 +1,262 @@
+// Package when reads the time of a reminder written in English or Russian:
+// "in 2h", "через 30 минут", "tomorrow 9:00", "завтра", "saturday evening", "в пятницу в 18:30", "25.12".
+package when
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHour is the time of days written without one, "tomorrow" is tomorrow at 9:00
+const defaultHour = 9
+
+var (
+	// compactDuration is a number with its unit, like "2d" or "30мин"
+	compactDuration = regexp.MustCompile(`^(\d+(?:\.\d+)?)(\p{L}+)$`)
+	clock           = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+)
+
+// relative start durations, "in 2 hours" and "через 2 часа"
+var relative = map[string]bool{"in": true, "через": true}
+
+// fillers are words that add nothing to the time
+var fillers = map[string]bool{
+	"at": true, "on": true, "this": true, "next": true, "and": true,
+	"в": true, "во": true, "на": true, "и": true,
+}
+
+var numbers = map[string]float64{"a": 1, "an": 1, "one": 1}
+
+var units = map[string]time.Duration{
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"мин": time.Minute, "минута": time.Minute, "минуту": time.Minute, "минуты": time.Minute, "минут": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"ч": time.Hour, "час": time.Hour, "часа": time.Hour, "часов": time.Hour, "полчаса": 30 * time.Minute,
+	"d": day, "day": day, "days": day,
+	"д": day, "день": day, "дня": day, "дней": day, "сутки": day, "суток": day,
+	"w": week, "week": week, "weeks": week,
+	"нед": week, "неделя": week, "неделю": week, "недели": week, "недель": week,
+}
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// days are counted from today
+var days = map[string]int{
+	"today": 0, "tomorrow": 1,
+	"сегодня": 0, "завтра": 1, "послезавтра": 2,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday, "воскресенье": time.Sunday, "вс": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday, "понедельник": time.Monday, "пн": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "вторник": time.Tuesday, "вт": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday, "среда": time.Wednesday, "среду": time.Wednesday, "ср": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "четверг": time.Thursday, "чт": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday, "пятница": time.Friday, "пятницу": time.Friday, "пт": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday, "суббота": time.Saturday, "субботу": time.Saturday, "сб": time.Saturday,
+}
+
+// partsOfDay are the hours of "evening" and "утром", with an hour after noon they make
+// the hours written with them afternoon ones: "evening at 8" and "в 8 вечера" are 20:00
+var partsOfDay = map[string]int{
+	"morning": 9, "noon": 12, "afternoon": 14, "evening": 19, "tonight": 19,
+	"утро": 9, "утром": 9, "утра": 9, "полдень": 12, "днем": 14, "вечер": 19, "вечером": 19, "вечера": 19,
+}
+
+// Parse returns the time the text refers to. Days and hours are in the location of now,
+// a day without an hour is at 9:00 and an hour that has passed today is tomorrow.
+func Parse(text string, now time.Time) (time.Time, error) {
+	words := strings.Fields(strings.NewReplacer(",", " ", "ё", "е").Replace(strings.ToLower(text)))
+	if len(words) == 0 {
+		return time.Time{}, NewUnknownTimeError(text)
+	}
+
+	var t time.Time
+	if relative[words[0]] {
+		d, ok := parseDuration(words[1:])
+		if !ok {
+			return time.Time{}, NewUnknownTimeError(text)
+		}
+		t = now.Add(d)
+	} else if d, ok := parseDuration(words); ok {
+		t = now.Add(d)
+	} else if t, ok = parseDate(words, now); !ok {
+		return time.Time{}, NewUnknownTimeError(text)
+	}
+
+	if !t.After(now) {
+		return time.Time{}, NewPastTimeError(text)
+	}
+	return t, nil
+}
+
+// parseDuration sums "2 hours 30 minutes", "1h30m", "2d" or "час", a unit without a number is one
+func parseDuration(words []string) (time.Duration, bool) {
+	var total time.Duration
+	n := -1.0
+	for _, w := range words {
+		if d, err := time.ParseDuration(w); err == nil && d > 0 {
+			total += d
+			continue
+		}
+		if m := compactDuration.FindStringSubmatch(w); m != nil {
+			v, _ := strconv.ParseFloat(m[1], 64)
+			unit, ok := units[m[2]]
+			if !ok {
+				return 0, false
+			}
+			total += time.Duration(v * float64(unit))
+			continue
+		}
+		if unit, ok := units[w]; ok {
+			if n < 0 {
+				n = 1
+			}
+			total += time.Duration(n * float64(unit))
+			n = -1
+			continue
+		}
+		if fillers[w] {
+			continue
+		}
+
+		v, ok := numbers[w]
+		if !ok {
+			var err error
+			if v, err = strconv.ParseFloat(w, 64); err != nil {
+				return 0, false
+			}
+		}
+		if n >= 0 || v <= 0 {
+			return 0, false
+		}
+		n = v
+	}
+	return total, total > 0 && n < 0
+}
+
+// parseDate reads a day and an hour, either of them may be left out
+func parseDate(words []string, now time.Time) (time.Time, bool) {
+	loc := now.Location()
+	offset, weekday, part, hour, minute := -1, -1, -1, -1, 0
+	twelve := false
+	var date time.Time
+	for _, w := range words {
+		if fillers[w] {
+			continue
+		}
+		if d, ok := days[w]; ok {
+			offset = d
+			continue
+		}
+		if d, ok := weekdays[w]; ok {
+			weekday = int(d)
+			continue
+		}
+		if h, ok := partsOfDay[w]; ok {
+			if part < 0 {
+				part = h
+			}
+			continue
+		}
+		if (w == "pm" || w == "am") && hour >= 0 && !twelve {
+			if hour == 0 || hour > 12 {
+				return time.Time{}, false
+			}
+			hour = twelveHour(hour, w)
+			twelve = true
+			continue
+		}
+		if d, ok := parseDay(w, now); ok {
+			date = d
+			continue
+		}
+
+		m := clock.FindStringSubmatch(w)
+		if m == nil {
+			return time.Time{}, false
+		}
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		twelve = m[3] != ""
+		if twelve {
+			if hour == 0 || hour > 12 {
+				return time.Time{}, false
+			}
+			hour = twelveHour(hour, m[3])
+		}
+		if hour > 23 || minute > 59 {
+			return time.Time{}, false
+		}
+	}
+
+	if offset < 0 && weekday < 0 && date.IsZero() && hour < 0 && part < 0 {
+		return time.Time{}, false
+	}
+	switch {
+	case hour < 0 && part >= 0:
+		hour = part
+	case hour < 0:
+		hour = defaultHour
+	case !twelve && part >= 12 && hour >= 1 && hour < 12:
+		hour += 12
+	}
+
+	if date.IsZero() {
+		date = now
+	}
+	t := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc)
+	switch {
+	case weekday >= 0:
+		t = t.AddDate(0, 0, (weekday-int(now.Weekday())+7)%7)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 7)
+		}
+	case offset >= 0:
+		t = t.AddDate(0, 0, offset)
+	case date.Equal(now) && !t.After(now):
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// parseDay reads "2024-12-25", "25.12.2024" or "25.12", a date without a year is the next one,
+// "29.02" is in the next leap year
+func parseDay(w string, now time.Time) (time.Time, bool) {
+	loc := now.Location()
+	for _, layout := range []string{"2006-01-02", "02.01.2006", "2.1.2006"} {
+		if t, err := time.ParseInLocation(layout, w, loc); err == nil {
+			return t, true
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	for _, layout := range []string{"02.01", "2.1"} {
+		d, err := time.ParseInLocation(layout, w, loc)
+		if err != nil {
+			continue
+		}
+		for year := now.Year(); year <= now.Year()+8; year++ {
+			t := time.Date(year, d.Month(), d.Day(), 0, 0, 0, 0, loc)
+			if t.Month() == d.Month() && !t.Before(today) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func twelveHour(hour int, suffix string) int {
+	if suffix == "pm" && hour < 12 {
+		return hour + 12
+	}
+	if suffix == "am" && hour == 12 {
+		return 0
+	}
+	return hour
+}
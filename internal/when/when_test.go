@@ -0,0 +1,114 @@
+package when
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var loc = time.FixedZone("MSK", 3*60*60)
+
+// now is Monday 10:00
+var now = time.Date(2026, time.October, 19, 10, 0, 0, 0, loc)
+
+func at(month time.Month, day int, hour int, minute int) time.Time {
+	return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		{"in 2h", at(time.October, 19, 12, 0)},
+		{"2d", at(time.October, 21, 10, 0)},
+		{"in 1 hour 30 minutes", at(time.October, 19, 11, 30)},
+		{"in an hour", at(time.October, 19, 11, 0)},
+		{"in a week", at(time.October, 26, 10, 0)},
+		{"1h30m", at(time.October, 19, 11, 30)},
+		{"через 30 минут", at(time.October, 19, 10, 30)},
+		{"через полчаса", at(time.October, 19, 10, 30)},
+		{"через 2 часа", at(time.October, 19, 12, 0)},
+		{"через неделю", at(time.October, 26, 10, 0)},
+		{"tomorrow", at(time.October, 20, 9, 0)},
+		{"Tomorrow 18:30", at(time.October, 20, 18, 30)},
+		{"завтра в 18:30", at(time.October, 20, 18, 30)},
+		{"послезавтра", at(time.October, 21, 9, 0)},
+		{"evening", at(time.October, 19, 19, 0)},
+		{"evening at 8", at(time.October, 19, 20, 0)},
+		{"tonight at 11", at(time.October, 19, 23, 0)},
+		{"afternoon at 3", at(time.October, 19, 15, 0)},
+		{"tomorrow morning at 8", at(time.October, 20, 8, 0)},
+		{"tomorrow 8 evening", at(time.October, 20, 20, 0)},
+		{"вечером в 8", at(time.October, 19, 20, 0)},
+		{"в 8 вечера", at(time.October, 19, 20, 0)},
+		{"завтра днем в 2", at(time.October, 20, 14, 0)},
+		{"завтра в 8 утра", at(time.October, 20, 8, 0)},
+		{"evening at 20:15", at(time.October, 19, 20, 15)},
+		{"at 8pm", at(time.October, 19, 20, 0)},
+		{"8 pm", at(time.October, 19, 20, 0)},
+		{"evening at 8am", at(time.October, 20, 8, 0)},
+		{"12am", at(time.October, 20, 0, 0)},
+		{"9:30", at(time.October, 20, 9, 30)},
+		{"saturday evening", at(time.October, 24, 19, 0)},
+		{"в пятницу в 18:30", at(time.October, 23, 18, 30)},
+		{"monday", at(time.October, 26, 9, 0)},
+		{"next mon at 7", at(time.October, 26, 7, 0)},
+		{"25.12", at(time.December, 25, 9, 0)},
+		{"1.1", time.Date(2027, time.January, 1, 9, 0, 0, 0, loc)},
+		{"29.02", time.Date(2028, time.February, 29, 9, 0, 0, 0, loc)},
+		{"2026-11-05 14:00", at(time.November, 5, 14, 0)},
+		{"5.11.2026 в 7 вечера", at(time.November, 5, 19, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text, now)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !got.Equal(tt.want) || got.Location() != loc {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		past bool
+	}{
+		{"", false},
+		{"soon", false},
+		{"in", false},
+		{"in 2", false},
+		{"in -2h", false},
+		{"2 hours tomorrow", false},
+		{"25:00", false},
+		{"10:75", false},
+		{"13pm", false},
+		{"0am", false},
+		{"30.02", false},
+		{"31.04", false},
+		{"31.04.2027", false},
+		{"29.02.2027", false},
+		{"today at 9", true},
+		{"2020-01-01", true},
+		{"сегодня утром", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(tt.text, now)
+			var pe *PastTimeError
+			var ue *UnknownTimeError
+			switch {
+			case tt.past && !errors.As(err, &pe):
+				t.Fatalf("got %v, %v, want PastTimeError", got, err)
+			case !tt.past && !errors.As(err, &ue):
+				t.Fatalf("got %v, %v, want UnknownTimeError", got, err)
+			}
+		})
+	}
+}